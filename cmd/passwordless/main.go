@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
 	"database/sql"
 	"errors"
 	"flag"
@@ -13,15 +14,24 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/metrics"
+	"github.com/nicolasparada/go-passwordless-demo/notification/mailbox"
+	smsnotification "github.com/nicolasparada/go-passwordless-demo/notification/sms"
 	smtpnotification "github.com/nicolasparada/go-passwordless-demo/notification/smtp"
+	"github.com/nicolasparada/go-passwordless-demo/notification/webhook"
 	"github.com/nicolasparada/go-passwordless-demo/repo/cockroach"
 	"github.com/nicolasparada/go-passwordless-demo/repo/cockroach/migrations"
+	"github.com/nicolasparada/go-passwordless-demo/tracing"
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+	grpctransport "github.com/nicolasparada/go-passwordless-demo/transport/grpc"
 	httptransport "github.com/nicolasparada/go-passwordless-demo/transport/http"
 )
 
@@ -42,6 +52,8 @@ func main() {
 func run(ctx context.Context, logger *log.Logger, args []string) error {
 	var (
 		port, _        = strconv.ParseUint(env("PORT", "3000"), 10, 64)
+		grpcPort, _    = strconv.ParseUint(os.Getenv("GRPC_PORT"), 10, 64)
+		adminPort, _   = strconv.ParseUint(os.Getenv("ADMIN_PORT"), 10, 64)
 		databaseURL    = env("DATABASE_URL", "postgresql://root@127.0.0.1:26257/passwordless?sslmode=disable")
 		usePostgres, _ = strconv.ParseBool(os.Getenv("USE_POSTGRES"))
 		migrate, _     = strconv.ParseBool(os.Getenv("MIGRATE"))
@@ -49,21 +61,102 @@ func run(ctx context.Context, logger *log.Logger, args []string) error {
 		smtpPort, _    = strconv.ParseUint(os.Getenv("SMTP_PORT"), 10, 64)
 		smtpUsername   = os.Getenv("SMTP_USERNAME")
 		smtpPassword   = os.Getenv("SMTP_PASSWORD")
+		smtpTLS        = os.Getenv("SMTP_TLS")
+		smtpAuth       = os.Getenv("SMTP_AUTH")
+		smtpCAFile     = os.Getenv("SMTP_CA_FILE")
+		mailTmplDir    = os.Getenv("MAIL_TEMPLATES_DIR")
+		dkimDomain     = os.Getenv("DKIM_DOMAIN")
+		dkimSelector   = env("DKIM_SELECTOR", "passwordless")
+		dkimKeyFile    = os.Getenv("DKIM_PRIVATE_KEY_FILE")
+		webhookURL     = os.Getenv("WEBHOOK_URL")
+		webhookSecret  = os.Getenv("WEBHOOK_SECRET")
+		emailEventsKey = os.Getenv("EMAIL_EVENTS_SECRET")
+		smsGatewayURL  = os.Getenv("SMS_GATEWAY_URL")
+		smsAPIKey      = os.Getenv("SMS_API_KEY")
+		smsFrom        = os.Getenv("SMS_FROM")
 		originStr      = env("ORIGIN", fmt.Sprintf("http://localhost:%d", port))
 		authTokenKey   = env("AUTH_TOKEN_KEY", "supersecretkeyyoushouldnotcommit")
+
+		devMailbox, _ = strconv.ParseBool(os.Getenv("DEV_MAILBOX"))
+		devMailboxDir = os.Getenv("DEV_MAILBOX_DIR")
+
+		emailLowercaseLocalPart, _ = strconv.ParseBool(os.Getenv("EMAIL_LOWERCASE_LOCAL_PART"))
+		emailStripSubaddress, _    = strconv.ParseBool(os.Getenv("EMAIL_STRIP_SUBADDRESS"))
+		loginAlerts, _             = strconv.ParseBool(os.Getenv("LOGIN_ALERTS"))
+		cookieSessions, _          = strconv.ParseBool(os.Getenv("COOKIE_SESSIONS"))
+		trustedOrigins             = os.Getenv("TRUSTED_ORIGINS")
+		corsAllowCredentials, _    = strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS"))
+		corsAllowedHeaders         = os.Getenv("CORS_ALLOWED_HEADERS")
+		corsMaxAge, _              = time.ParseDuration(env("CORS_MAX_AGE", "0"))
+		contentSecurityPolicy      = os.Getenv("CONTENT_SECURITY_POLICY")
+		hstsMaxAge, _              = time.ParseDuration(env("HSTS_MAX_AGE", "0"))
+		traceExporter              = os.Getenv("TRACE_EXPORTER")
+		otlpEndpoint               = env("OTLP_ENDPOINT", "localhost:4318")
+		otlpInsecure, _            = strconv.ParseBool(env("OTLP_INSECURE", "true"))
 	)
 
 	fs := flag.NewFlagSet("passwordless", flag.ExitOnError)
 	fs.Uint64Var(&port, "port", port, "HTTP port in which this very server listen")
+	fs.Uint64Var(&grpcPort, "grpc-port", grpcPort, "gRPC port in which this very server listen. Disabled if not set")
+	fs.Uint64Var(&adminPort, "admin-port", adminPort, "Port to serve Prometheus /metrics. Disabled if not set")
 	fs.StringVar(&databaseURL, "db", databaseURL, "Cockroach database URL")
 	fs.BoolVar(&usePostgres, "use-postgres", usePostgres, "Tries to use postgres instead of cockroach")
 	fs.BoolVar(&migrate, "migrate", migrate, "Whether migrate database schema")
 	fs.StringVar(&originStr, "origin", originStr, "URL origin of this very server")
+	fs.BoolVar(&devMailbox, "dev-mailbox", devMailbox, "Development only. Keeps emails instead of sending them and serves them at /dev/mailbox")
+	fs.StringVar(&devMailboxDir, "dev-mailbox-dir", devMailboxDir, "Directory to keep dev mailbox emails as .eml files. Defaults to memory")
+	fs.StringVar(&smtpTLS, "smtp-tls", smtpTLS, `SMTP TLS mode: "starttls", "tls" or "none". Defaults to "starttls"`)
+	fs.StringVar(&smtpAuth, "smtp-auth", smtpAuth, `SMTP auth mechanism: "PLAIN", "LOGIN" or "CRAM-MD5". Defaults to "PLAIN"`)
+	fs.StringVar(&smtpCAFile, "smtp-ca-file", smtpCAFile, "PEM file with the CA certificates to trust the SMTP server. Defaults to the system ones")
+	fs.StringVar(&mailTmplDir, "mail-templates-dir", mailTmplDir, "Directory with <locale>/<name>.tmpl mail templates overriding the embedded ones. Reloaded on change")
+	fs.StringVar(&dkimKeyFile, "dkim-key-file", dkimKeyFile, "PEM file with the RSA or Ed25519 private key to DKIM sign emails. Emails are not signed without it")
+	fs.StringVar(&dkimSelector, "dkim-selector", dkimSelector, "DKIM selector")
+	fs.StringVar(&dkimDomain, "dkim-domain", dkimDomain, "DKIM signing domain. Defaults to the origin hostname")
+	fs.StringVar(&webhookURL, "webhook-url", webhookURL, "URL to POST notifications to instead of sending emails. Signed with WEBHOOK_SECRET")
+	fs.StringVar(&smsGatewayURL, "sms-gateway", smsGatewayURL, "URL of the HTTP SMS gateway. Phone numbers are disabled without it")
+	fs.BoolVar(&emailLowercaseLocalPart, "email-lowercase-local-part", emailLowercaseLocalPart, "Whether lowercase the part before the @ of emails")
+	fs.BoolVar(&emailStripSubaddress, "email-strip-subaddress", emailStripSubaddress, "Whether remove +tag suffixes from emails")
+	fs.BoolVar(&loginAlerts, "login-alerts", loginAlerts, "Whether email users each time they login")
+	fs.StringVar(&trustedOrigins, "trusted-origins", trustedOrigins, "Comma separated origins, besides the own one, allowed as redirect URIs and to call the API cross-origin")
+	fs.BoolVar(&corsAllowCredentials, "cors-allow-credentials", corsAllowCredentials, "Whether let trusted origins send cookies in cross-origin API requests")
+	fs.StringVar(&corsAllowedHeaders, "cors-allowed-headers", corsAllowedHeaders, "Comma separated request headers trusted origins can send. Defaults to the ones the API reads")
+	fs.DurationVar(&corsMaxAge, "cors-max-age", corsMaxAge, "How long browsers can cache CORS preflight responses. Defaults to 10 minutes")
+	fs.StringVar(&contentSecurityPolicy, "csp", contentSecurityPolicy, "Content-Security-Policy header. Defaults to a strict one only allowing same-origin scripts and styles")
+	fs.DurationVar(&hstsMaxAge, "hsts-max-age", hstsMaxAge, "Strict-Transport-Security max age. Defaults to 180 days with an HTTPS origin. Negative disables it")
+	fs.BoolVar(&cookieSessions, "cookie-sessions", cookieSessions, "Whether keep logins in an HttpOnly cookie instead of giving the auth token to the client")
+	fs.StringVar(&traceExporter, "trace-exporter", traceExporter, `OpenTelemetry trace exporter: "otlp" or "stdout". Tracing is disabled if not set`)
+	fs.StringVar(&otlpEndpoint, "otlp-endpoint", otlpEndpoint, "host:port of the OpenTelemetry collector receiving OTLP over HTTP")
+	fs.BoolVar(&otlpInsecure, "otlp-insecure", otlpInsecure, "Whether send traces to the collector without TLS")
 
 	if err := fs.Parse(args); err != nil {
 		return fmt.Errorf("could not parse flags: %w", err)
 	}
 
+	exporter, err := tracing.ParseExporter(traceExporter)
+	if err != nil {
+		return err
+	}
+
+	if exporter != "" {
+		shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+			Exporter:     exporter,
+			OTLPEndpoint: otlpEndpoint,
+			OTLPInsecure: otlpInsecure,
+		})
+		if err != nil {
+			return fmt.Errorf("could not setup tracing: %w", err)
+		}
+
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			if err := shutdownTracing(ctx); err != nil {
+				logger.Printf("could not flush traces: %v\n", err)
+			}
+		}()
+	}
+
 	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
 		return fmt.Errorf("could not open cockroach db: %w", err)
@@ -82,7 +175,7 @@ func run(ctx context.Context, logger *log.Logger, args []string) error {
 				return fmt.Errorf("could not migrate sql schema: %w", err)
 			}
 		}
-		_, err := db.ExecContext(ctx, migrations.Schema)
+		err := migrations.Migrate(ctx, db)
 		if err != nil {
 			return fmt.Errorf("could not migrate sql schema: %w", err)
 		}
@@ -97,33 +190,243 @@ func run(ctx context.Context, logger *log.Logger, args []string) error {
 		return errors.New("origin must be absolute")
 	}
 
+	if hstsMaxAge == 0 && origin.Scheme == "https" {
+		hstsMaxAge = time.Hour * 24 * 180
+	}
+
 	repo := &cockroach.Repository{DB: db, DisableCRDBRetries: usePostgres}
 	mailFromName := "Passwordless"
 	mailFromAddress := "noreply@" + origin.Hostname()
-	magicLinkComposer, err := smtpnotification.MagicLinkComposer(
-		mailFromName, mailFromAddress,
-	)
+	mailTmpls, err := smtpnotification.LoadTemplates(mailTmplDir)
 	if err != nil {
-		return fmt.Errorf("could not create magic link composer: %w", err)
+		return fmt.Errorf("could not load mail templates: %w", err)
+	}
+
+	if mailTmplDir != "" {
+		go mailTmpls.Watch(ctx, logger)
 	}
 
-	magicLinkSender := &smtpnotification.Sender{
-		FromName:    mailFromName,
-		FromAddress: mailFromAddress,
-		Host:        smtpHost,
-		Port:        smtpPort,
-		Username:    smtpUsername,
-		Password:    smtpPassword,
-		ComposeFunc: magicLinkComposer,
+	mailComposer := mailTmpls.Composer(mailFromName, mailFromAddress)
+
+	var m *metrics.Metrics
+	if adminPort != 0 {
+		m = metrics.New()
+		m.RegisterDB(db, "cockroach")
 	}
+
+	var (
+		emailSender      passwordless.NotificationSender
+		emailSenderName  string
+		devMailboxSender *mailbox.Sender
+	)
+	if devMailbox {
+		logger.Println("dev mailbox enabled: emails are not being sent")
+		if devMailboxDir != "" {
+			err := os.MkdirAll(devMailboxDir, 0o755)
+			if err != nil {
+				return fmt.Errorf("could not create dev mailbox dir: %w", err)
+			}
+		}
+
+		devMailboxSender = &mailbox.Sender{
+			ComposeFunc: mailComposer,
+			Dir:         devMailboxDir,
+		}
+		emailSender = devMailboxSender
+		emailSenderName = "mailbox"
+	} else if webhookURL != "" {
+		if webhookSecret == "" {
+			return errors.New("webhook secret required")
+		}
+
+		logger.Println("webhook enabled: emails are not being sent")
+		emailSender = &webhook.Sender{
+			URL:    webhookURL,
+			Secret: webhookSecret,
+		}
+		emailSenderName = "webhook"
+	} else {
+		tlsMode, err := smtpnotification.ParseTLSMode(smtpTLS)
+		if err != nil {
+			return err
+		}
+
+		authMechanism, err := smtpnotification.ParseAuthMechanism(smtpAuth)
+		if err != nil {
+			return err
+		}
+
+		var rootCAs *x509.CertPool
+		if smtpCAFile != "" {
+			b, err := os.ReadFile(smtpCAFile)
+			if err != nil {
+				return fmt.Errorf("could not read smtp ca file: %w", err)
+			}
+
+			rootCAs = x509.NewCertPool()
+			if !rootCAs.AppendCertsFromPEM(b) {
+				return errors.New("could not parse smtp ca file: no pem certificates found")
+			}
+		}
+
+		var dkim *smtpnotification.DKIM
+		if dkimKeyFile != "" {
+			key, err := smtpnotification.LoadDKIMKey(dkimKeyFile)
+			if err != nil {
+				return err
+			}
+
+			if dkimDomain == "" {
+				dkimDomain = origin.Hostname()
+			}
+
+			dkim = &smtpnotification.DKIM{
+				Domain:   dkimDomain,
+				Selector: dkimSelector,
+				Signer:   key,
+			}
+		}
+
+		smtpSender := &smtpnotification.Sender{
+			FromName:      mailFromName,
+			FromAddress:   mailFromAddress,
+			Host:          smtpHost,
+			Port:          smtpPort,
+			Username:      smtpUsername,
+			Password:      smtpPassword,
+			TLSMode:       tlsMode,
+			RootCAs:       rootCAs,
+			AuthMechanism: authMechanism,
+			DKIM:          dkim,
+			ComposeFunc:   mailComposer,
+		}
+
+		defer smtpSender.Close()
+
+		emailSender = smtpSender
+		emailSenderName = "smtp"
+	}
+
+	if m != nil {
+		emailSender = m.InstrumentSender(emailSenderName, emailSender)
+	}
+
+	var smsSender passwordless.NotificationSender
+	if smsGatewayURL != "" {
+		smsComposer, err := smsnotification.Composer()
+		if err != nil {
+			return fmt.Errorf("could not create sms composer: %w", err)
+		}
+
+		smsSender = &smsnotification.Sender{
+			GatewayURL:  smsGatewayURL,
+			APIKey:      smsAPIKey,
+			From:        smsFrom,
+			ComposeFunc: smsComposer,
+		}
+
+		if m != nil {
+			smsSender = m.InstrumentSender("sms", smsSender)
+		}
+	}
+
 	svc := &passwordless.Service{
-		Logger:          logger,
-		Origin:          origin,
-		Repository:      repo,
-		MagicLinkSender: magicLinkSender,
-		AuthTokenKey:    authTokenKey,
+		Logger:         logger,
+		Origin:         origin,
+		TrustedOrigins: splitList(trustedOrigins),
+		Repository:     repo,
+		EmailSender:    emailSender,
+		SMSSender:      smsSender,
+		AuthTokenKey:   authTokenKey,
+		EmailNormalization: passwordless.EmailNormalization{
+			LowercaseLocalPart: emailLowercaseLocalPart,
+			StripSubaddress:    emailStripSubaddress,
+		},
+		LoginAlerts: loginAlerts,
+	}
+
+	var (
+		tsvc            transport.Service = svc
+		instrumentRoute func(string, http.Handler) http.Handler
+	)
+	if m != nil {
+		tsvc = m.InstrumentService(svc)
+		instrumentRoute = m.InstrumentRoute
+	}
+	if exporter != "" {
+		tsvc = tracing.InstrumentService(tsvc)
+		instrumentRoute = withTracing(instrumentRoute)
+	}
+
+	h := httptransport.NewHandler(tsvc, logger, httptransport.Options{
+		DevMailbox:        devMailboxSender,
+		EmailEventsSecret: emailEventsKey,
+		CookieSessions:    cookieSessions,
+		CORS: httptransport.CORSOptions{
+			AllowedHeaders:   splitList(corsAllowedHeaders),
+			AllowCredentials: corsAllowCredentials,
+			MaxAge:           corsMaxAge,
+		},
+		SecurityHeaders: httptransport.SecurityHeadersOptions{
+			ContentSecurityPolicy: contentSecurityPolicy,
+			HSTSMaxAge:            hstsMaxAge,
+		},
+		InstrumentRoute: instrumentRoute,
+	})
+
+	outboxCtx, cancelOutbox := context.WithCancel(ctx)
+	outboxDone := make(chan struct{})
+	go func() {
+		defer close(outboxDone)
+		svc.RunOutboxDispatcher(outboxCtx)
+	}()
+
+	defer func() {
+		cancelOutbox()
+		<-outboxDone
+	}()
+
+	if grpcPort != 0 {
+		grpcSrv := grpctransport.NewServer(tsvc, logger)
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+		if err != nil {
+			return fmt.Errorf("could not grpc listen: %w", err)
+		}
+
+		go func() {
+			<-ctx.Done()
+			grpcSrv.GracefulStop()
+		}()
+
+		go func() {
+			logger.Printf("accepting grpc connections at %s\n", lis.Addr())
+			if err := grpcSrv.Serve(lis); err != nil {
+				logger.Printf("could not grpc serve: %v\n", err)
+			}
+		}()
+	}
+
+	if m != nil {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("/metrics", m.Handler())
+		adminSrv := &http.Server{
+			Handler: adminMux,
+			Addr:    fmt.Sprintf(":%d", adminPort),
+		}
+
+		go func() {
+			<-ctx.Done()
+			adminSrv.Close()
+		}()
+
+		go func() {
+			logger.Printf("serving metrics at %s/metrics\n", adminSrv.Addr)
+			err := adminSrv.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				logger.Printf("could not admin listen and serve: %v\n", err)
+			}
+		}()
 	}
-	h := httptransport.NewHandler(svc, logger)
 
 	srv := &http.Server{
 		Handler: h,
@@ -155,6 +458,17 @@ func run(ctx context.Context, logger *log.Logger, args []string) error {
 	return nil
 }
 
+// withTracing wraps routes with a span
+// around whatever instrument already does.
+func withTracing(instrument func(string, http.Handler) http.Handler) func(string, http.Handler) http.Handler {
+	return func(route string, next http.Handler) http.Handler {
+		if instrument != nil {
+			next = instrument(route, next)
+		}
+		return tracing.InstrumentRoute(route, next)
+	}
+}
+
 func env(key, fallback string) string {
 	v, ok := os.LookupEnv(key)
 	if !ok {
@@ -163,3 +477,13 @@ func env(key, fallback string) string {
 
 	return v
 }
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
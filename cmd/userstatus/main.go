@@ -0,0 +1,105 @@
+// Command userstatus suspends, bans or reactivates a user.
+//
+//	userstatus -user jane@example.org -status suspended -reason spam -for 72h
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	"github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/repo/cockroach"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	logger := log.Default()
+	err := run(ctx, os.Args[1:])
+	if err != nil {
+		logger.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	var (
+		databaseURL = env("DATABASE_URL", "postgresql://root@127.0.0.1:26257/passwordless?sslmode=disable")
+		user        string
+		status      = string(passwordless.UserStatusSuspended)
+		reason      string
+		duration    time.Duration
+	)
+
+	fs := flag.NewFlagSet("userstatus", flag.ExitOnError)
+	fs.StringVar(&databaseURL, "db", databaseURL, "Cockroach database URL")
+	fs.StringVar(&user, "user", user, "ID or email of the user")
+	fs.StringVar(&status, "status", status, "New user status: active, suspended or banned")
+	fs.StringVar(&reason, "reason", reason, "Reason shown to the user")
+	fs.DurationVar(&duration, "for", duration, "How long the status lasts. Zero means forever")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("could not parse flags: %w", err)
+	}
+
+	if user == "" {
+		return errors.New("user required")
+	}
+
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return fmt.Errorf("could not open cockroach db: %w", err)
+	}
+
+	defer db.Close()
+
+	repo := &cockroach.Repository{DB: db}
+	svc := &passwordless.Service{Repository: repo}
+
+	userID := user
+	if strings.Contains(user, "@") {
+		u, err := repo.UserByEmail(ctx, user)
+		if err != nil {
+			return fmt.Errorf("could not fetch user by email: %w", err)
+		}
+
+		userID = u.ID
+	}
+
+	var expiresAt *time.Time
+	if duration > 0 {
+		t := time.Now().Add(duration)
+		expiresAt = &t
+	}
+
+	u, err := svc.SetUserStatus(ctx, userID, passwordless.UserStatus(status), reason, expiresAt)
+	if err != nil {
+		return fmt.Errorf("could not set user status: %w", err)
+	}
+
+	fmt.Printf("user %s (%s) is now %s\n", u.Username, u.ID, u.Status)
+	return nil
+}
+
+func env(key, fallback string) string {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	return v
+}
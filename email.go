@@ -0,0 +1,76 @@
+package passwordless
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxEmailLength          = 254
+	maxEmailLocalPartLength = 64
+)
+
+var reEmail = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+
+// EmailNormalization configures how the local part
+// (the one before the "@") of email addresses gets normalized.
+// The domain part is always lowercased and IDNA encoded.
+type EmailNormalization struct {
+	// LowercaseLocalPart lowercases the local part.
+	// RFC 5321 says it's case-sensitive, but almost no provider treats it like so.
+	LowercaseLocalPart bool
+	// StripSubaddress removes "+tag" suffixes from the local part.
+	StripSubaddress bool
+}
+
+// normalizeEmail validates and normalizes the given email address.
+// Addresses that only differ in the case of their local part
+// are still considered the same user by the repository.
+func (n EmailNormalization) normalizeEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	at := strings.LastIndex(s, "@")
+	if at <= 0 || at == len(s)-1 {
+		return "", ErrInvalidEmail
+	}
+
+	local, domain := s[:at], s[at+1:]
+
+	if n.StripSubaddress {
+		if i := strings.Index(local, "+"); i > 0 {
+			local = local[:i]
+		}
+	}
+
+	if n.LowercaseLocalPart {
+		local = strings.ToLower(local)
+	}
+
+	if len(local) > maxEmailLocalPartLength || strings.ContainsAny(local, "@") || !isPrintableNonSpace(local) {
+		return "", ErrInvalidEmail
+	}
+
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil || !strings.Contains(domain, ".") {
+		return "", ErrInvalidEmail
+	}
+
+	email := local + "@" + domain
+	if len(email) > maxEmailLength || !reEmail.MatchString(email) {
+		return "", ErrInvalidEmail
+	}
+
+	return email, nil
+}
+
+func isPrintableNonSpace(s string) bool {
+	for _, r := range s {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
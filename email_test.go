@@ -0,0 +1,42 @@
+package passwordless
+
+import "testing"
+
+func TestEmailNormalization_normalizeEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       EmailNormalization
+		email   string
+		want    string
+		wantErr bool
+	}{
+		{name: "lowercases domain", email: "Foo@Example.COM", want: "Foo@example.com"},
+		{name: "trims spaces", email: "  foo@example.com\n", want: "foo@example.com"},
+		{name: "trailing dot", email: "foo@example.com.", want: "foo@example.com"},
+		{name: "idna domain", email: "foo@Bücher.example", want: "foo@xn--bcher-kva.example"},
+		{name: "lowercase local part", n: EmailNormalization{LowercaseLocalPart: true}, email: "Foo@Example.com", want: "foo@example.com"},
+		{name: "keeps subaddress", email: "foo+news@example.com", want: "foo+news@example.com"},
+		{name: "strips subaddress", n: EmailNormalization{StripSubaddress: true}, email: "foo+news@example.com", want: "foo@example.com"},
+		{name: "leading plus", n: EmailNormalization{StripSubaddress: true}, email: "+foo@example.com", want: "+foo@example.com"},
+		{name: "empty", email: "", wantErr: true},
+		{name: "missing at", email: "foo.example.com", wantErr: true},
+		{name: "missing local part", email: "@example.com", wantErr: true},
+		{name: "missing domain", email: "foo@", wantErr: true},
+		{name: "domain without dot", email: "foo@localhost", wantErr: true},
+		{name: "space in local part", email: "foo bar@example.com", wantErr: true},
+		{name: "invalid domain", email: "foo@exa mple.com", wantErr: true},
+		{name: "display name", email: "Foo <foo@example.com>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.n.normalizeEmail(tt.email)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeEmail(%q) error = %v, wantErr %v", tt.email, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("normalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
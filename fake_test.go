@@ -0,0 +1,457 @@
+package passwordless
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+// memRepo is an in-memory Repository for service tests.
+// Transactions do not roll back.
+type memRepo struct {
+	mu               sync.Mutex
+	verificationCode map[string]VerificationCode
+	users            map[string]User
+	phoneNumberCodes map[string]PhoneNumberCode
+	released         []ReleasedUsername
+	outbox           map[string]*OutboxMessage
+	suppressions     map[string]Suppression
+	// calls records outbox delivery outcomes as "Method id".
+	calls []string
+}
+
+func newMemRepo() *memRepo {
+	return &memRepo{
+		verificationCode: map[string]VerificationCode{},
+		users:            map[string]User{},
+		phoneNumberCodes: map[string]PhoneNumberCode{},
+		outbox:           map[string]*OutboxMessage{},
+		suppressions:     map[string]Suppression{},
+	}
+}
+
+func (repo *memRepo) ExecuteTx(ctx context.Context, txFunc func(ctx context.Context) error) error {
+	return txFunc(ctx)
+}
+
+func (repo *memRepo) StoreVerificationCode(ctx context.Context, email string) (VerificationCode, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	vc := VerificationCode{Email: email, Code: newTestUUID(), CreatedAt: time.Now()}
+	repo.verificationCode[email+" "+vc.Code] = vc
+	return vc, nil
+}
+
+func (repo *memRepo) VerificationCode(ctx context.Context, email, code string) (VerificationCode, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	vc, ok := repo.verificationCode[email+" "+code]
+	if !ok {
+		return vc, ErrVerificationCodeNotFound
+	}
+
+	return vc, nil
+}
+
+func (repo *memRepo) DeleteVerificationCode(ctx context.Context, email, code string) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, ok := repo.verificationCode[email+" "+code]
+	delete(repo.verificationCode, email+" "+code)
+	return ok, nil
+}
+
+func (repo *memRepo) UserExistsByEmail(ctx context.Context, email string) (bool, error) {
+	_, err := repo.UserByEmail(ctx, email)
+	if err == ErrUserNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (repo *memRepo) UserByEmail(ctx context.Context, email string) (User, error) {
+	return repo.findUser(func(u User) bool { return strings.EqualFold(u.Email, email) })
+}
+
+func (repo *memRepo) UserByPhoneNumber(ctx context.Context, phoneNumber string) (User, error) {
+	return repo.findUser(func(u User) bool { return u.PhoneNumber != nil && *u.PhoneNumber == phoneNumber })
+}
+
+func (repo *memRepo) UserByUsername(ctx context.Context, username string) (User, error) {
+	return repo.findUser(func(u User) bool { return u.Username == username })
+}
+
+func (repo *memRepo) findUser(match func(u User) bool) (User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, u := range repo.users {
+		if match(u) {
+			return u, nil
+		}
+	}
+
+	return User{}, ErrUserNotFound
+}
+
+func (repo *memRepo) StoreUser(ctx context.Context, email, username string) (User, error) {
+	if _, err := repo.UserByEmail(ctx, email); err == nil {
+		return User{}, ErrEmailTaken
+	}
+
+	if _, err := repo.UserByUsername(ctx, username); err == nil {
+		return User{}, ErrUsernameTaken
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	u := User{
+		ID:        newTestUUID(),
+		Email:     email,
+		Username:  username,
+		CreatedAt: time.Now(),
+		Status:    UserStatusActive,
+	}
+	repo.users[u.ID] = u
+	return u, nil
+}
+
+func (repo *memRepo) User(ctx context.Context, userID string) (User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	u, ok := repo.users[userID]
+	if !ok {
+		return u, ErrUserNotFound
+	}
+
+	return u, nil
+}
+
+func (repo *memRepo) UpdateUser(ctx context.Context, userID string, params UpdateUserParams) (User, error) {
+	if params.PhoneNumber != nil && *params.PhoneNumber != "" {
+		other, err := repo.UserByPhoneNumber(ctx, *params.PhoneNumber)
+		if err == nil && other.ID != userID {
+			return User{}, ErrPhoneNumberTaken
+		}
+	}
+
+	return repo.updateUser(userID, func(u *User) {
+		set := func(dst **string, v *string) {
+			if v == nil {
+				return
+			}
+
+			*dst = nil
+			if *v != "" {
+				s := *v
+				*dst = &s
+			}
+		}
+		set(&u.DisplayName, params.DisplayName)
+		set(&u.AvatarURL, params.AvatarURL)
+		set(&u.Locale, params.Locale)
+		set(&u.TimeZone, params.TimeZone)
+		set(&u.PhoneNumber, params.PhoneNumber)
+	})
+}
+
+func (repo *memRepo) UpdateUserLastLogin(ctx context.Context, userID string) (time.Time, error) {
+	now := time.Now()
+	_, err := repo.updateUser(userID, func(u *User) { u.LastLoginAt = &now })
+	return now, err
+}
+
+func (repo *memRepo) UpdateUserStatus(ctx context.Context, userID string, status UserStatus, reason string, expiresAt *time.Time) (User, error) {
+	return repo.updateUser(userID, func(u *User) {
+		u.Status = status
+		u.StatusReason = nil
+		if reason != "" {
+			u.StatusReason = &reason
+		}
+		u.StatusExpiresAt = expiresAt
+	})
+}
+
+func (repo *memRepo) UpdateUsername(ctx context.Context, userID, username string) (User, error) {
+	if other, err := repo.UserByUsername(ctx, username); err == nil && other.ID != userID {
+		return User{}, ErrUsernameTaken
+	}
+
+	return repo.updateUser(userID, func(u *User) { u.Username = username })
+}
+
+func (repo *memRepo) updateUser(userID string, update func(u *User)) (User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	u, ok := repo.users[userID]
+	if !ok {
+		return u, ErrUserNotFound
+	}
+
+	update(&u)
+	repo.users[userID] = u
+	return u, nil
+}
+
+func (repo *memRepo) StorePhoneNumberCode(ctx context.Context, userID, phoneNumber, code string) (PhoneNumberCode, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	pc := PhoneNumberCode{UserID: userID, PhoneNumber: phoneNumber, Code: code, CreatedAt: time.Now()}
+	repo.phoneNumberCodes[userID] = pc
+	return pc, nil
+}
+
+func (repo *memRepo) PhoneNumberCode(ctx context.Context, userID string) (PhoneNumberCode, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	pc, ok := repo.phoneNumberCodes[userID]
+	if !ok {
+		return pc, ErrPhoneNumberCodeNotFound
+	}
+
+	return pc, nil
+}
+
+func (repo *memRepo) DeletePhoneNumberCode(ctx context.Context, userID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.phoneNumberCodes, userID)
+	return nil
+}
+
+func (repo *memRepo) StoreReleasedUsername(ctx context.Context, userID, username string) (ReleasedUsername, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	rel := ReleasedUsername{Username: username, UserID: userID, ReleasedAt: time.Now()}
+	repo.released = append(repo.released, rel)
+	return rel, nil
+}
+
+func (repo *memRepo) ReleasedUsername(ctx context.Context, username string) (ReleasedUsername, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var latest *ReleasedUsername
+	for i, rel := range repo.released {
+		if rel.Username == username && (latest == nil || !rel.ReleasedAt.Before(latest.ReleasedAt)) {
+			latest = &repo.released[i]
+		}
+	}
+
+	if latest == nil {
+		return ReleasedUsername{}, ErrReleasedUsernameNotFound
+	}
+
+	return *latest, nil
+}
+
+func (repo *memRepo) StoreOutboxMessage(ctx context.Context, channel OutboxChannel, to string, payload []byte, traceParent string) (OutboxMessage, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	msg := &OutboxMessage{
+		ID:            newTestUUID(),
+		Channel:       channel,
+		To:            to,
+		Payload:       payload,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if traceParent != "" {
+		msg.TraceParent = &traceParent
+	}
+	repo.outbox[msg.ID] = msg
+	return *msg, nil
+}
+
+func (repo *memRepo) OutboxMessage(ctx context.Context, id string) (OutboxMessage, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	msg, ok := repo.outbox[id]
+	if !ok {
+		return OutboxMessage{}, ErrOutboxMessageNotFound
+	}
+
+	return *msg, nil
+}
+
+// outboxMessages returns the stored outbox messages oldest first.
+func (repo *memRepo) outboxMessages() []OutboxMessage {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var msgs []OutboxMessage
+	for _, msg := range repo.outbox {
+		msgs = append(msgs, *msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].CreatedAt.Before(msgs[j].CreatedAt) })
+	return msgs
+}
+
+func (repo *memRepo) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	var msgs []OutboxMessage
+	for _, msg := range repo.outbox {
+		if len(msgs) == limit {
+			break
+		}
+
+		if msg.Status != OutboxStatusPending || msg.NextAttemptAt.After(now) {
+			continue
+		}
+
+		msg.Attempts++
+		msg.NextAttemptAt = now.Add(lease)
+		msgs = append(msgs, *msg)
+	}
+	return msgs, nil
+}
+
+func (repo *memRepo) MarkOutboxMessageDelivered(ctx context.Context, id string) error {
+	return repo.updateOutboxMessage("MarkOutboxMessageDelivered", id, func(msg *OutboxMessage) {
+		now := time.Now()
+		msg.Status = OutboxStatusDelivered
+		msg.DeliveredAt = &now
+		msg.Payload = json.RawMessage("null")
+	})
+}
+
+func (repo *memRepo) RetryOutboxMessage(ctx context.Context, id, lastErr string, delay time.Duration) error {
+	return repo.updateOutboxMessage("RetryOutboxMessage", id, func(msg *OutboxMessage) {
+		msg.LastError = &lastErr
+		msg.NextAttemptAt = time.Now().Add(delay)
+	})
+}
+
+func (repo *memRepo) DeadLetterOutboxMessage(ctx context.Context, id, lastErr string) error {
+	return repo.updateOutboxMessage("DeadLetterOutboxMessage", id, func(msg *OutboxMessage) {
+		msg.Status = OutboxStatusDead
+		msg.LastError = &lastErr
+	})
+}
+
+func (repo *memRepo) updateOutboxMessage(method, id string, update func(msg *OutboxMessage)) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.calls = append(repo.calls, method+" "+id)
+
+	msg, ok := repo.outbox[id]
+	if !ok {
+		return ErrOutboxMessageNotFound
+	}
+
+	update(msg)
+	return nil
+}
+
+func (repo *memRepo) StoreSuppression(ctx context.Context, email string, reason SuppressionReason, detail string) (Suppression, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	s := Suppression{Email: strings.ToLower(email), Reason: reason, CreatedAt: now, UpdatedAt: now}
+	if detail != "" {
+		s.Detail = &detail
+	}
+	repo.suppressions[s.Email] = s
+	return s, nil
+}
+
+func (repo *memRepo) Suppression(ctx context.Context, email string) (Suppression, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	s, ok := repo.suppressions[strings.ToLower(email)]
+	if !ok {
+		return s, ErrSuppressionNotFound
+	}
+
+	return s, nil
+}
+
+// fakeSender records sent messages, failing the first failures sends.
+type fakeSender struct {
+	mu       sync.Mutex
+	failures int
+	sent     []string
+}
+
+func (s *fakeSender) Send(ctx context.Context, msg notification.Message, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("could not send %s message to %s", msg.MessageType(), to)
+	}
+
+	s.sent = append(s.sent, to)
+	return nil
+}
+
+func newTestService(t *testing.T, repo Repository) *Service {
+	t.Helper()
+	return &Service{
+		Logger:       log.New(io.Discard, "", 0),
+		Origin:       &url.URL{Scheme: "https", Host: "example.org"},
+		Repository:   repo,
+		EmailSender:  &fakeSender{},
+		SMSSender:    &fakeSender{},
+		AuthTokenKey: "supersecretkeyyoushouldnotcommit",
+	}
+}
+
+// storeTestUser stores an active user with the given username.
+func storeTestUser(t *testing.T, repo Repository, username string) User {
+	t.Helper()
+	u, err := repo.StoreUser(context.Background(), username+"@example.org", username)
+	if err != nil {
+		t.Fatalf("StoreUser() error = %v", err)
+	}
+
+	return u
+}
+
+func newTestUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func authContext(u User) context.Context {
+	return context.WithValue(context.Background(), KeyAuthUserID, u.ID)
+}
@@ -0,0 +1,54 @@
+// Package httputil holds HTTP helpers shared by the HTTP transport
+// and its instrumentation.
+package httputil
+
+import "net/http"
+
+// ResponseRecorder records the status code and size of the response
+// so middlewares and instrumentation can inspect it once served.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *ResponseRecorder) WriteHeader(statusCode int) {
+	if rec.status == 0 {
+		rec.status = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *ResponseRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *ResponseRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Written tells whether the response status was already sent.
+func (rec *ResponseRecorder) Written() bool {
+	return rec.status != 0
+}
+
+// StatusCode is the status code of the response, defaulting to 200.
+func (rec *ResponseRecorder) StatusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+
+	return rec.status
+}
+
+// Bytes is the size of the response body written so far.
+func (rec *ResponseRecorder) Bytes() int {
+	return rec.bytes
+}
@@ -0,0 +1,39 @@
+package passwordless
+
+import (
+	"context"
+
+	"golang.org/x/text/language"
+)
+
+const maxPreferredLanguages = 5
+
+// preferredLanguages returns the languages to write notifications in,
+// most preferred first: the user's locale, if any,
+// followed by the ones accepted by the request.
+func preferredLanguages(ctx context.Context, u User) []string {
+	var langs []string
+	if u.Locale != nil {
+		langs = append(langs, *u.Locale)
+	}
+
+	header, _ := ctx.Value(KeyAcceptLanguage).(string)
+	if header == "" {
+		return langs
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return langs
+	}
+
+	for _, tag := range tags {
+		if len(langs) == maxPreferredLanguages {
+			break
+		}
+
+		langs = append(langs, tag.String())
+	}
+
+	return langs
+}
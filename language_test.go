@@ -0,0 +1,32 @@
+package passwordless
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_preferredLanguages(t *testing.T) {
+	es := "es"
+	tests := []struct {
+		name           string
+		locale         *string
+		acceptLanguage string
+		want           []string
+	}{
+		{name: "none"},
+		{name: "user locale", locale: &es, want: []string{"es"}},
+		{name: "accept language", acceptLanguage: "fr-CH, fr;q=0.9, en;q=0.8", want: []string{"fr-CH", "fr", "en"}},
+		{name: "user locale first", locale: &es, acceptLanguage: "en", want: []string{"es", "en"}},
+		{name: "invalid accept language", locale: &es, acceptLanguage: "en;q=nope", want: []string{"es"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), KeyAcceptLanguage, tt.acceptLanguage)
+			got := preferredLanguages(ctx, User{Locale: tt.locale})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("preferredLanguages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
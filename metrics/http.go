@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/internal/httputil"
+)
+
+// InstrumentRoute counts requests to route and measures their latency.
+// route should be the pattern the handler is registered with
+// and not the request path, to keep the labels count bounded.
+func (m *Metrics) InstrumentRoute(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &httputil.ResponseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		method := methodLabel(r.Method)
+		m.httpRequests.WithLabelValues(route, method, strconv.Itoa(rec.StatusCode())).Inc()
+		m.httpDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// methodLabel keeps the labels count bounded
+// since clients can send any method.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+
+	return "OTHER"
+}
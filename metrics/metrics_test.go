@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+)
+
+type serviceStub struct {
+	transport.Service
+	err error
+}
+
+func (s serviceStub) VerifyMagicLink(ctx context.Context, email, code string, username *string) (passwordless.Auth, error) {
+	return passwordless.Auth{}, s.err
+}
+
+type senderFunc func(ctx context.Context, msg notification.Message, to string) error
+
+func (fn senderFunc) Send(ctx context.Context, msg notification.Message, to string) error {
+	return fn(ctx, msg, to)
+}
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	for _, err := range []error{nil, passwordless.ErrVerificationCodeExpired, errors.New("connection reset")} {
+		_, _ = m.InstrumentService(serviceStub{err: err}).VerifyMagicLink(context.Background(), "", "", nil)
+	}
+
+	sender := m.InstrumentSender("smtp", senderFunc(func(ctx context.Context, msg notification.Message, to string) error {
+		return nil
+	}))
+	_ = sender.Send(context.Background(), notification.MagicLinkData{}, "jane@example.org")
+
+	h := m.InstrumentRoute("/api/users/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/users/jane", nil))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	b, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(b)
+	for _, want := range []string{
+		`passwordless_service_calls_total{method="VerifyMagicLink",outcome="ok"} 1`,
+		`passwordless_service_calls_total{method="VerifyMagicLink",outcome="verification_code_expired"} 1`,
+		`passwordless_service_calls_total{method="VerifyMagicLink",outcome="internal_error"} 1`,
+		`passwordless_notification_send_duration_seconds_count{outcome="ok",sender="smtp"} 1`,
+		`passwordless_http_requests_total{code="404",method="GET",route="/api/users/"} 1`,
+		`passwordless_http_request_duration_seconds_count{method="GET",route="/api/users/"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+}
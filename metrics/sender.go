@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+// InstrumentSender measures how long sender takes to send each notification.
+// name labels the sender, like "smtp".
+func (m *Metrics) InstrumentSender(name string, sender passwordless.NotificationSender) passwordless.NotificationSender {
+	return &notificationSender{sender: sender, name: name, metrics: m}
+}
+
+type notificationSender struct {
+	sender  passwordless.NotificationSender
+	name    string
+	metrics *Metrics
+}
+
+func (s *notificationSender) Send(ctx context.Context, msg notification.Message, to string) error {
+	start := time.Now()
+	err := s.sender.Send(ctx, msg, to)
+	o := "ok"
+	if err != nil {
+		o = "error"
+	}
+	s.metrics.notificationDuration.WithLabelValues(s.name, o).Observe(time.Since(start).Seconds())
+	return err
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+)
+
+// InstrumentService counts SendMagicLink and VerifyMagicLink outcomes.
+func (m *Metrics) InstrumentService(svc transport.Service) transport.Service {
+	return &service{Service: svc, metrics: m}
+}
+
+type service struct {
+	transport.Service
+	metrics *Metrics
+}
+
+func (s *service) SendMagicLink(ctx context.Context, to, redirectURI string) (passwordless.OutboxMessage, error) {
+	msg, err := s.Service.SendMagicLink(ctx, to, redirectURI)
+	s.metrics.serviceCalls.WithLabelValues("SendMagicLink", outcome(err)).Inc()
+	return msg, err
+}
+
+func (s *service) VerifyMagicLink(ctx context.Context, email, code string, username *string) (passwordless.Auth, error) {
+	auth, err := s.Service.VerifyMagicLink(ctx, email, code, username)
+	s.metrics.serviceCalls.WithLabelValues("VerifyMagicLink", outcome(err)).Inc()
+	return auth, err
+}
+
+// outcome labels err by its stable code.
+// Unexpected errors are all labeled the same
+// to keep the labels count bounded.
+func outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return "canceled"
+	}
+
+	return passwordless.ErrorCode(err)
+}
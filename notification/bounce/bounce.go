@@ -0,0 +1,191 @@
+// Package bounce parses bounce and complaint reports
+// sent back by mail servers and email service providers.
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+type EventType string
+
+const (
+	EventTypeBounce    EventType = "bounce"
+	EventTypeComplaint EventType = "complaint"
+)
+
+// Event is a bounce or complaint about a recipient.
+type Event struct {
+	Type  EventType `json:"type"`
+	Email string    `json:"email"`
+	// Permanent tells a hard bounce. Always true for complaints.
+	Permanent bool   `json:"permanent"`
+	Detail    string `json:"detail"`
+}
+
+// ErrNotReport is returned for messages that are not
+// a delivery status notification nor a feedback report.
+var ErrNotReport = errors.New("not a delivery status notification nor a feedback report")
+
+// ParseMessage parses a whole email message carrying
+// a delivery status notification (RFC 3464)
+// or an abuse feedback report (RFC 5965).
+func ParseMessage(r io.Reader) ([]Event, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read report message: %w", err)
+	}
+
+	return ParseReport(m.Header.Get("Content-Type"), m.Body)
+}
+
+// ParseReport parses a multipart/report body with the given content type.
+// Only failed recipients of delivery status notifications are returned.
+func ParseReport(contentType string, body io.Reader) ([]Event, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/report" {
+		return nil, ErrNotReport
+	}
+
+	reportType := strings.ToLower(params["report-type"])
+	if reportType != "delivery-status" && reportType != "feedback-report" {
+		return nil, ErrNotReport
+	}
+
+	var (
+		events   []Event
+		feedback textproto.MIMEHeader
+		original *mail.Header
+	)
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read report part: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			evs, err := parseDeliveryStatus(p)
+			if err != nil {
+				return nil, err
+			}
+
+			events = append(events, evs...)
+		case "message/feedback-report":
+			feedback, err = textproto.NewReader(bufio.NewReader(p)).ReadMIMEHeader()
+			if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("could not read feedback report: %w", err)
+			}
+		case "message/rfc822", "text/rfc822-headers":
+			if m, err := mail.ReadMessage(p); err == nil {
+				original = &m.Header
+			}
+		}
+	}
+
+	if reportType == "feedback-report" {
+		return feedbackEvents(feedback, original)
+	}
+
+	return events, nil
+}
+
+// parseDeliveryStatus parses the per-message fields block
+// followed by one block of fields per recipient.
+func parseDeliveryStatus(r io.Reader) ([]Event, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read delivery status: %w", err)
+	}
+
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	blocks := bytes.Split(bytes.TrimSpace(b), []byte("\n\n"))
+	if len(blocks) < 2 {
+		return nil, errors.New("delivery status without recipients")
+	}
+
+	var events []Event
+	for _, block := range blocks[1:] {
+		tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(block, '\n', '\n'))))
+		h, err := tr.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("could not read delivery status recipient fields: %w", err)
+		}
+
+		if !strings.EqualFold(strings.TrimSpace(h.Get("Action")), "failed") {
+			continue
+		}
+
+		email := addressField(h.Get("Final-Recipient"))
+		if email == "" {
+			email = addressField(h.Get("Original-Recipient"))
+		}
+
+		if email == "" {
+			continue
+		}
+
+		status := strings.TrimSpace(h.Get("Status"))
+		detail := strings.TrimSpace(status + " " + typedField(h.Get("Diagnostic-Code")))
+		events = append(events, Event{
+			Type:      EventTypeBounce,
+			Email:     email,
+			Permanent: strings.HasPrefix(status, "5"),
+			Detail:    detail,
+		})
+	}
+
+	return events, nil
+}
+
+func feedbackEvents(feedback textproto.MIMEHeader, original *mail.Header) ([]Event, error) {
+	if feedback == nil {
+		return nil, errors.New("feedback report without feedback fields")
+	}
+
+	email := addressField(feedback.Get("Original-Rcpt-To"))
+	if email == "" && original != nil {
+		if addr, err := mail.ParseAddress(original.Get("To")); err == nil {
+			email = addr.Address
+		}
+	}
+
+	if email == "" {
+		return nil, errors.New("feedback report without recipient")
+	}
+
+	return []Event{{
+		Type:      EventTypeComplaint,
+		Email:     email,
+		Permanent: true,
+		Detail:    strings.TrimSpace(feedback.Get("Feedback-Type")),
+	}}, nil
+}
+
+// typedField strips the type from fields like "smtp; 550 user unknown".
+func typedField(s string) string {
+	if i := strings.IndexByte(s, ';'); i != -1 {
+		s = s[i+1:]
+	}
+
+	return strings.TrimSpace(s)
+}
+
+// addressField parses fields like "rfc822; <jane@example.org>".
+func addressField(s string) string {
+	return strings.Trim(typedField(s), "<>")
+}
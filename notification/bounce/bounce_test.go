@@ -0,0 +1,110 @@
+package bounce
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const dsnMessage = "From: Mail Delivery System <MAILER-DAEMON@mx.example.org>\r\n" +
+	"To: noreply@example.com\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"BOUNDARY\"\r\n" +
+	"\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"I'm sorry to have to inform you that your message could not be delivered.\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.example.org\r\n" +
+	"Arrival-Date: Mon, 19 Oct 2026 10:00:00 +0000\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; jane@example.org\r\n" +
+	"Original-Recipient: rfc822;jane@example.org\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 user unknown\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; john@example.org\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.4.1\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; <full@example.org>\r\n" +
+	"Action: failed\r\n" +
+	"Status: 4.2.2\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"To: jane@example.org\r\n" +
+	"Subject: Login to Golang Passwordless Demo\r\n" +
+	"--BOUNDARY--\r\n"
+
+const arfMessage = "From: abuse@mailbox.example\r\n" +
+	"Subject: FW: Login to Golang Passwordless Demo\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=feedback-report; boundary=\"BOUNDARY\"\r\n" +
+	"\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"This is an email abuse report.\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: message/feedback-report\r\n" +
+	"\r\n" +
+	"Feedback-Type: abuse\r\n" +
+	"User-Agent: SomeGenerator/1.0\r\n" +
+	"Version: 1\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: message/rfc822\r\n" +
+	"\r\n" +
+	"From: noreply@example.com\r\n" +
+	"To: Jane <jane@example.org>\r\n" +
+	"Subject: Login to Golang Passwordless Demo\r\n" +
+	"\r\n" +
+	"Open the link.\r\n" +
+	"--BOUNDARY--\r\n"
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     string
+		want    []Event
+		wantErr error
+	}{
+		{
+			name: "delivery status",
+			msg:  dsnMessage,
+			want: []Event{
+				{Type: EventTypeBounce, Email: "jane@example.org", Permanent: true, Detail: "5.1.1 550 5.1.1 user unknown"},
+				{Type: EventTypeBounce, Email: "full@example.org", Permanent: false, Detail: "4.2.2"},
+			},
+		},
+		{
+			name: "feedback report",
+			msg:  arfMessage,
+			want: []Event{
+				{Type: EventTypeComplaint, Email: "jane@example.org", Permanent: true, Detail: "abuse"},
+			},
+		},
+		{
+			name:    "not a report",
+			msg:     "Content-Type: text/plain\r\n\r\nhello\r\n",
+			wantErr: ErrNotReport,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMessage(strings.NewReader(tt.msg))
+			if err != tt.wantErr {
+				t.Fatalf("ParseMessage() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMessage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,14 @@ package notification
 import (
 	"context"
 	"io"
+	"time"
+
+	"github.com/hako/durafmt"
 )
 
 type ComposeFunc func(ctx context.Context, to string, w io.Writer, data interface{}) error
+
+// HumanDuration formats d using its largest unit only. Like "20 minutes".
+func HumanDuration(d time.Duration) string {
+	return durafmt.Parse(d).LimitFirstN(1).String()
+}
@@ -0,0 +1,60 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/hako/durafmt"
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when none of the preferred languages is available.
+const DefaultLocale = "en"
+
+// durationUnits holds the durafmt units of each language other than english.
+var durationUnits = map[string]string{
+	"es": "año:años,semana:semanas,día:días,hora:horas,minuto:minutos,segundo:segundos,milisegundo:milisegundos,microsegundo:microsegundos",
+}
+
+// HumanDurationIn returns a func like HumanDuration
+// but with units written in the given locale.
+// Falls back to english units for unknown languages.
+func HumanDurationIn(locale string) func(d time.Duration) string {
+	base, _ := language.Make(locale).Base()
+	s, ok := durationUnits[base.String()]
+	if !ok {
+		return HumanDuration
+	}
+
+	units, err := durafmt.DefaultUnitsCoder.Decode(s)
+	if err != nil {
+		panic("invalid " + base.String() + " duration units: " + err.Error())
+	}
+
+	return func(d time.Duration) string {
+		return durafmt.Parse(d).LimitFirstN(1).Format(units)
+	}
+}
+
+// MatchLocale returns the available locale that best matches
+// the preferred languages. The first available locale is the fallback.
+func MatchLocale(available, preferred []string) string {
+	if len(available) == 0 {
+		return DefaultLocale
+	}
+
+	supported := make([]language.Tag, len(available))
+	for i, s := range available {
+		supported[i] = language.Make(s)
+	}
+
+	var tags []language.Tag
+	for _, s := range preferred {
+		tag, err := language.Parse(s)
+		if err == nil {
+			tags = append(tags, tag)
+		}
+	}
+
+	_, i, _ := language.NewMatcher(supported).Match(tags...)
+	return available[i]
+}
@@ -0,0 +1,44 @@
+package notification
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchLocale(t *testing.T) {
+	available := []string{"en", "es"}
+	tests := []struct {
+		preferred []string
+		want      string
+	}{
+		{preferred: nil, want: "en"},
+		{preferred: []string{"es"}, want: "es"},
+		{preferred: []string{"es-CL", "en"}, want: "es"},
+		{preferred: []string{"fr", "es"}, want: "es"},
+		{preferred: []string{"fr"}, want: "en"},
+		{preferred: []string{"not a language", "es"}, want: "es"},
+	}
+	for _, tt := range tests {
+		if got := MatchLocale(available, tt.preferred); got != tt.want {
+			t.Errorf("MatchLocale(%v) = %q, want %q", tt.preferred, got, tt.want)
+		}
+	}
+}
+
+func TestHumanDurationIn(t *testing.T) {
+	tests := []struct {
+		locale string
+		d      time.Duration
+		want   string
+	}{
+		{locale: "en", d: time.Minute * 20, want: "20 minutes"},
+		{locale: "es", d: time.Minute * 20, want: "20 minutos"},
+		{locale: "es-CL", d: time.Hour, want: "1 hora"},
+		{locale: "fr", d: time.Hour, want: "1 hour"},
+	}
+	for _, tt := range tests {
+		if got := HumanDurationIn(tt.locale)(tt.d); got != tt.want {
+			t.Errorf("HumanDurationIn(%q)(%s) = %q, want %q", tt.locale, tt.d, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package notification
 
 import (
-	"net/url"
 	"time"
 )
 
 type MagicLinkData struct {
-	Origin    *url.URL
-	TTL       time.Duration
-	MagicLink *url.URL
+	Origin    URL           `json:"origin"`
+	TTL       time.Duration `json:"ttl"`
+	MagicLink URL           `json:"magicLink"`
+	// Languages to write the message in, most preferred first.
+	Languages []string `json:"languages,omitempty"`
 }
+
+func (data MagicLinkData) MessageType() MessageType     { return MessageTypeMagicLink }
+func (data MagicLinkData) PreferredLanguages() []string { return data.Languages }
@@ -0,0 +1,92 @@
+package mailbox
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Content is the human readable part of a message.
+type Content struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Links   []string
+}
+
+var reLink = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// Content parses the raw MIME message.
+func (msg Message) Content() (Content, error) {
+	var c Content
+
+	m, err := mail.ReadMessage(bytes.NewReader(msg.Raw))
+	if err != nil {
+		return c, fmt.Errorf("could not parse mailbox message: %w", err)
+	}
+
+	dec := &mime.WordDecoder{}
+	c.From, _ = dec.DecodeHeader(m.Header.Get("From"))
+	c.To, _ = dec.DecodeHeader(m.Header.Get("To"))
+	c.Subject, _ = dec.DecodeHeader(m.Header.Get("Subject"))
+
+	err = readPart(&c, m.Header.Get("Content-Type"), m.Body)
+	if err != nil {
+		return c, err
+	}
+
+	// Plain text templates may escape "&" inside query strings.
+	for _, link := range reLink.FindAllString(c.Text, -1) {
+		c.Links = append(c.Links, html.UnescapeString(link))
+	}
+
+	return c, nil
+}
+
+func readPart(c *Content, contentType string, r io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+
+			if err != nil {
+				return fmt.Errorf("could not read mailbox message part: %w", err)
+			}
+
+			err = readPart(c, p.Header.Get("Content-Type"), p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("could not read mailbox message body: %w", err)
+	}
+
+	switch mediaType {
+	case "text/plain":
+		c.Text = string(b)
+	case "text/html":
+		c.HTML = string(b)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+// Package mailbox provides a development notification sender
+// that keeps messages around instead of delivering them.
+package mailbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+// maxMessages is how many messages are kept in memory.
+const maxMessages = 100
+
+var ErrMessageNotFound = errors.New("message not found")
+
+// Sender keeps composed messages in memory,
+// or as .eml files inside Dir when set.
+type Sender struct {
+	ComposeFunc notification.ComposeFunc
+	Dir         string
+
+	mu   sync.Mutex
+	msgs []Message
+}
+
+type Message struct {
+	ID        string
+	CreatedAt time.Time
+	Raw       []byte
+}
+
+func (s *Sender) Send(ctx context.Context, data notification.Message, to string) error {
+	raw := &bytes.Buffer{}
+	err := s.ComposeFunc(ctx, to, raw, data)
+	if err != nil {
+		return fmt.Errorf("could not compose %s message: %w", data.MessageType(), err)
+	}
+
+	id, err := genID()
+	if err != nil {
+		return err
+	}
+
+	msg := Message{ID: id, CreatedAt: time.Now(), Raw: raw.Bytes()}
+
+	if s.Dir != "" {
+		name := filepath.Join(s.Dir, fileName(msg))
+		err := os.WriteFile(name, msg.Raw, 0o644)
+		if err != nil {
+			return fmt.Errorf("could not write mailbox message file: %w", err)
+		}
+
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.msgs = append(s.msgs, msg)
+	if len(s.msgs) > maxMessages {
+		s.msgs = s.msgs[len(s.msgs)-maxMessages:]
+	}
+
+	return nil
+}
+
+// Messages returns the kept messages, newest first.
+func (s *Sender) Messages() ([]Message, error) {
+	if s.Dir != "" {
+		return s.readDir()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]Message, len(s.msgs))
+	for i, msg := range s.msgs {
+		msgs[len(msgs)-1-i] = msg
+	}
+
+	return msgs, nil
+}
+
+func (s *Sender) Message(id string) (Message, error) {
+	msgs, err := s.Messages()
+	if err != nil {
+		return Message{}, err
+	}
+
+	for _, msg := range msgs {
+		if msg.ID == id {
+			return msg, nil
+		}
+	}
+
+	return Message{}, ErrMessageNotFound
+}
+
+func (s *Sender) readDir() ([]Message, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read mailbox dir: %w", err)
+	}
+
+	var msgs []Message
+	for _, entry := range entries {
+		msg, ok := parseFileName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		msg.Raw, err = os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read mailbox message file: %w", err)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].CreatedAt.After(msgs[j].CreatedAt)
+	})
+
+	return msgs, nil
+}
+
+// fileName is like "1618000000000000000-a1b2c3d4e5f6a7b8.eml".
+func fileName(msg Message) string {
+	return strconv.FormatInt(msg.CreatedAt.UnixNano(), 10) + "-" + msg.ID + ".eml"
+}
+
+func parseFileName(name string) (Message, bool) {
+	var msg Message
+
+	if !strings.HasSuffix(name, ".eml") {
+		return msg, false
+	}
+
+	name = strings.TrimSuffix(name, ".eml")
+	parts := strings.SplitN(name, "-", 2)
+	if len(parts) != 2 {
+		return msg, false
+	}
+
+	nsec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return msg, false
+	}
+
+	msg.ID = parts[1]
+	msg.CreatedAt = time.Unix(0, nsec)
+	return msg, true
+}
+
+func genID() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("could not generate mailbox message id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,85 @@
+package mailbox
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+	"github.com/nicolasparada/go-passwordless-demo/notification/smtp"
+)
+
+func TestSender(t *testing.T) {
+	composeFunc, err := smtp.Composer("Passwordless", "noreply@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	magicLink := &url.URL{
+		Scheme:   "https",
+		Host:     "example.org",
+		Path:     "/api/verify-magic-link",
+		RawQuery: url.Values{"email": []string{"jane@example.org"}, "code": []string{"123"}}.Encode(),
+	}
+	data := notification.MagicLinkData{
+		Origin:    notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org"}},
+		TTL:       time.Minute * 20,
+		MagicLink: notification.URL{URL: magicLink},
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+	}{
+		{name: "memory"},
+		{name: "dir", dir: t.TempDir()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Sender{ComposeFunc: composeFunc, Dir: tt.dir}
+			for _, to := range []string{"john@example.org", "jane@example.org"} {
+				err := s.Send(context.Background(), data, to)
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+
+			msgs, err := s.Messages()
+			if err != nil {
+				t.Fatalf("Messages() error = %v", err)
+			}
+
+			if len(msgs) != 2 {
+				t.Fatalf("Messages() len = %d, want 2", len(msgs))
+			}
+
+			msg, err := s.Message(msgs[0].ID)
+			if err != nil {
+				t.Fatalf("Message() error = %v", err)
+			}
+
+			c, err := msg.Content()
+			if err != nil {
+				t.Fatalf("Content() error = %v", err)
+			}
+
+			if c.To != "<jane@example.org>" {
+				t.Errorf("Content() to = %q, want newest message first", c.To)
+			}
+
+			if c.Subject == "" || c.HTML == "" || c.Text == "" {
+				t.Errorf("Content() = %+v, want subject, html and text", c)
+			}
+
+			if len(c.Links) != 1 || c.Links[0] != magicLink.String() {
+				t.Errorf("Content() links = %v, want [%s]", c.Links, magicLink)
+			}
+
+			_, err = s.Message("missing")
+			if err != ErrMessageNotFound {
+				t.Errorf("Message() with missing id error = %v, want %v", err, ErrMessageNotFound)
+			}
+		})
+	}
+}
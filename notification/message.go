@@ -0,0 +1,187 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// MessageType names a kind of message.
+// Templates for each type are named after it.
+type MessageType string
+
+const (
+	MessageTypeMagicLink     MessageType = "magic-link"
+	MessageTypeWelcome       MessageType = "welcome"
+	MessageTypeLoginAlert    MessageType = "login-alert"
+	MessageTypeAccountChange MessageType = "account-change"
+	// MessageTypePhoneNumberCode is only sent by SMS.
+	MessageTypePhoneNumberCode MessageType = "phone-number-code"
+)
+
+// Message is the data of a notification. Each type has its own.
+type Message interface {
+	MessageType() MessageType
+	// PreferredLanguages to write the message in, most preferred first.
+	PreferredLanguages() []string
+}
+
+// MessageTypes lists every known message type.
+var MessageTypes = []MessageType{
+	MessageTypeMagicLink,
+	MessageTypeWelcome,
+	MessageTypeLoginAlert,
+	MessageTypeAccountChange,
+	MessageTypePhoneNumberCode,
+}
+
+// MailMessageTypes lists the message types sent by mail.
+var MailMessageTypes = []MessageType{
+	MessageTypeMagicLink,
+	MessageTypeWelcome,
+	MessageTypeLoginAlert,
+	MessageTypeAccountChange,
+}
+
+var unmarshalers = map[MessageType]func(b []byte) (Message, error){
+	MessageTypeMagicLink:       unmarshalData[MagicLinkData],
+	MessageTypeWelcome:         unmarshalData[WelcomeData],
+	MessageTypeLoginAlert:      unmarshalData[LoginAlertData],
+	MessageTypeAccountChange:   unmarshalData[AccountChangeData],
+	MessageTypePhoneNumberCode: unmarshalData[PhoneNumberCodeData],
+}
+
+// unmarshalData decodes the data of a message by value,
+// since messages are sent by value.
+func unmarshalData[T Message](b []byte) (Message, error) {
+	var data T
+	err := json.Unmarshal(b, &data)
+	return data, err
+}
+
+type messageJSON struct {
+	Type MessageType     `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// MarshalMessage encodes msg along its type so UnmarshalMessage
+// can decode it back to the right data type.
+func MarshalMessage(msg Message) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("could not json marshal %s message: %w", msg.MessageType(), err)
+	}
+
+	return json.Marshal(messageJSON{Type: msg.MessageType(), Data: data})
+}
+
+// UnmarshalMessage decodes a message encoded by MarshalMessage.
+// Bare MagicLinkData, from before messages had types, is decoded as such.
+func UnmarshalMessage(b []byte) (Message, error) {
+	var v messageJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("could not json unmarshal message: %w", err)
+	}
+
+	if v.Type == "" {
+		v.Type, v.Data = MessageTypeMagicLink, b
+	}
+
+	unmarshal, ok := unmarshalers[v.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown message type %q", v.Type)
+	}
+
+	msg, err := unmarshal(v.Data)
+	if err != nil {
+		return nil, fmt.Errorf("could not json unmarshal %s message: %w", v.Type, err)
+	}
+
+	return msg, nil
+}
+
+// WelcomeData is sent to new users after their first login.
+type WelcomeData struct {
+	Origin    URL      `json:"origin"`
+	Username  string   `json:"username"`
+	Languages []string `json:"languages,omitempty"`
+}
+
+func (data WelcomeData) MessageType() MessageType     { return MessageTypeWelcome }
+func (data WelcomeData) PreferredLanguages() []string { return data.Languages }
+
+// LoginAlertData tells users somebody logged into their account.
+type LoginAlertData struct {
+	Origin URL `json:"origin"`
+	// At is in the user's time zone, if any.
+	At        time.Time `json:"at"`
+	Languages []string  `json:"languages,omitempty"`
+}
+
+func (data LoginAlertData) MessageType() MessageType     { return MessageTypeLoginAlert }
+func (data LoginAlertData) PreferredLanguages() []string { return data.Languages }
+
+// AccountChange names what changed in an account.
+type AccountChange string
+
+const (
+	AccountChangeUsername    AccountChange = "username"
+	AccountChangePhoneNumber AccountChange = "phoneNumber"
+)
+
+// AccountChangeData tells users something in their account changed
+// so they can react if it wasn't them.
+type AccountChangeData struct {
+	Origin    URL           `json:"origin"`
+	Change    AccountChange `json:"change"`
+	Languages []string      `json:"languages,omitempty"`
+}
+
+func (data AccountChangeData) MessageType() MessageType     { return MessageTypeAccountChange }
+func (data AccountChangeData) PreferredLanguages() []string { return data.Languages }
+
+// PhoneNumberCodeData is texted to a new phone number
+// with the code to confirm it.
+type PhoneNumberCodeData struct {
+	Origin    URL           `json:"origin"`
+	Code      string        `json:"code"`
+	TTL       time.Duration `json:"ttl"`
+	Languages []string      `json:"languages,omitempty"`
+}
+
+func (data PhoneNumberCodeData) MessageType() MessageType     { return MessageTypePhoneNumberCode }
+func (data PhoneNumberCodeData) PreferredLanguages() []string { return data.Languages }
+
+// URL is encoded in JSON as a string.
+type URL struct {
+	*url.URL
+}
+
+func (u URL) MarshalJSON() ([]byte, error) {
+	if u.URL == nil {
+		return json.Marshal("")
+	}
+
+	return json.Marshal(u.URL.String())
+}
+
+func (u *URL) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	if s == "" {
+		u.URL = nil
+		return nil
+	}
+
+	parsed, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("could not parse url: %w", err)
+	}
+
+	u.URL = parsed
+	return nil
+}
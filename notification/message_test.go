@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalMessage(t *testing.T) {
+	origin := URL{URL: &url.URL{Scheme: "https", Host: "example.org"}}
+	tests := []Message{
+		MagicLinkData{
+			Origin:    origin,
+			TTL:       time.Minute * 20,
+			MagicLink: URL{URL: &url.URL{Scheme: "https", Host: "example.org", Path: "/api/verify-magic-link"}},
+			Languages: []string{"es"},
+		},
+		WelcomeData{Origin: origin, Username: "john", Languages: []string{"en"}},
+		LoginAlertData{Origin: origin, At: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)},
+		AccountChangeData{Origin: origin, Change: AccountChangeUsername},
+		PhoneNumberCodeData{Origin: origin, Code: "012345", TTL: time.Minute * 10},
+	}
+	for _, want := range tests {
+		t.Run(string(want.MessageType()), func(t *testing.T) {
+			b, err := MarshalMessage(want)
+			if err != nil {
+				t.Fatalf("MarshalMessage() error = %v", err)
+			}
+
+			got, err := UnmarshalMessage(b)
+			if err != nil {
+				t.Fatalf("UnmarshalMessage() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("UnmarshalMessage() = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMessage(t *testing.T) {
+	t.Run("untyped magic link", func(t *testing.T) {
+		got, err := UnmarshalMessage([]byte(`{"origin":"https://example.org","ttl":1200000000000,"magicLink":"https://example.org/api/verify-magic-link"}`))
+		if err != nil {
+			t.Fatalf("UnmarshalMessage() error = %v", err)
+		}
+
+		data, ok := got.(MagicLinkData)
+		if !ok || data.TTL != time.Minute*20 || data.MagicLink.Path != "/api/verify-magic-link" {
+			t.Errorf("UnmarshalMessage() = %#v, want magic link data", got)
+		}
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		_, err := UnmarshalMessage([]byte(`{"type":"nope","data":{}}`))
+		if err == nil {
+			t.Error("UnmarshalMessage() error = nil, want unknown type error")
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"text/template"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+	"github.com/nicolasparada/go-passwordless-demo/web"
+)
+
+var tmplFuncs = template.FuncMap{
+	"human_duration": notification.HumanDuration,
+}
+
+// Composer handles web/template/sms/<type>.txt.tmpl composing
+// where type is the notification.MessageType.
+// Not every message type has an SMS template.
+// Uses notification.Message as data.
+func Composer() (notification.ComposeFunc, error) {
+	tmpls := map[notification.MessageType]*template.Template{}
+	for _, msgType := range notification.MessageTypes {
+		b, err := web.Files.ReadFile("template/sms/" + string(msgType) + ".txt.tmpl")
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s sms template file: %w", msgType, err)
+		}
+
+		tmpl, err := template.New("sms/" + string(msgType) + ".txt").Funcs(tmplFuncs).Parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s sms template: %w", msgType, err)
+		}
+
+		tmpls[msgType] = tmpl
+	}
+
+	composeFunc := func(ctx context.Context, phoneNumber string, w io.Writer, v interface{}) error {
+		msg, ok := v.(notification.Message)
+		if !ok {
+			return fmt.Errorf("unexpected message type %T", v)
+		}
+
+		tmpl, ok := tmpls[msg.MessageType()]
+		if !ok {
+			return fmt.Errorf("no sms template for %q messages", msg.MessageType())
+		}
+
+		err := tmpl.Execute(w, msg)
+		if err != nil {
+			return fmt.Errorf("could not render %s sms template: %w", msg.MessageType(), err)
+		}
+
+		return nil
+	}
+
+	return composeFunc, nil
+}
@@ -0,0 +1,82 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+// Sender sends text messages through an HTTP SMS gateway.
+// It POSTs a JSON body like {"from": "", "to": "", "text": ""}
+// to GatewayURL with APIKey as bearer token.
+type Sender struct {
+	GatewayURL  string
+	APIKey      string
+	From        string
+	Client      *http.Client
+	ComposeFunc notification.ComposeFunc
+}
+
+type gatewayReqBody struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+func (s *Sender) Send(ctx context.Context, msg notification.Message, to string) error {
+	text := &strings.Builder{}
+	err := s.ComposeFunc(ctx, to, text, msg)
+	if err != nil {
+		return fmt.Errorf("could not compose %s sms: %w", msg.MessageType(), err)
+	}
+
+	b, err := json.Marshal(gatewayReqBody{
+		From: s.From,
+		To:   to,
+		Text: strings.TrimSpace(text.String()),
+	})
+	if err != nil {
+		return fmt.Errorf("could not json marshal sms gateway request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.GatewayURL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("could not create sms gateway request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("could not do sms gateway request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sms gateway responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
+
+func (s *Sender) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+
+	return defaultClient
+}
+
+var defaultClient = &http.Client{Timeout: time.Second * 10}
@@ -0,0 +1,84 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+func TestSender_Send(t *testing.T) {
+	composeFunc, err := Composer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := notification.MagicLinkData{
+		Origin:    notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org"}},
+		TTL:       time.Minute * 20,
+		MagicLink: notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org", Path: "/api/verify-magic-link", RawQuery: "code=123"}},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		var got gatewayReqBody
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				t.Errorf("method = %s, want POST", r.Method)
+			}
+
+			if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+				t.Errorf("authorization = %q, want %q", auth, "Bearer secret")
+			}
+
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("could not decode request body: %v", err)
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer srv.Close()
+
+		s := &Sender{
+			GatewayURL:  srv.URL,
+			APIKey:      "secret",
+			From:        "Passwordless",
+			Client:      srv.Client(),
+			ComposeFunc: composeFunc,
+		}
+		err := s.Send(context.Background(), data, "+56911111111")
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+
+		if got.From != "Passwordless" || got.To != "+56911111111" {
+			t.Errorf("Send() request body = %+v", got)
+		}
+
+		if !strings.Contains(got.Text, data.MagicLink.String()) || !strings.Contains(got.Text, "20 minutes") {
+			t.Errorf("Send() text = %q, want magic link and expiration", got.Text)
+		}
+	})
+
+	t.Run("gateway error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "invalid destination", http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		s := &Sender{
+			GatewayURL:  srv.URL,
+			Client:      srv.Client(),
+			ComposeFunc: composeFunc,
+		}
+		err := s.Send(context.Background(), data, "+56911111111")
+		if err == nil || !strings.Contains(err.Error(), "invalid destination") {
+			t.Errorf("Send() error = %v, want gateway error", err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+func (s *Sender) authMechanism() AuthMechanism {
+	if s.AuthMechanism == "" {
+		return AuthPlain
+	}
+
+	return s.AuthMechanism
+}
+
+func (s *Sender) auth() (smtp.Auth, error) {
+	switch m := s.authMechanism(); m {
+	case AuthPlain:
+		return smtp.PlainAuth("", s.Username, s.Password, s.Host), nil
+	case AuthLogin:
+		return &loginAuth{username: s.Username, password: s.Password, host: s.Host}, nil
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(s.Username, s.Password), nil
+	default:
+		return nil, fmt.Errorf("unknown smtp auth mechanism %q", m)
+	}
+}
+
+// loginAuth implements the non standard, but widely used, LOGIN mechanism.
+// Like smtp.PlainAuth, it refuses to send credentials
+// over unencrypted connections other than to localhost.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return string(AuthLogin), nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
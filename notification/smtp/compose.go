@@ -0,0 +1,220 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"net/mail"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/text/language"
+)
+
+// Composer handles web/template/mail/<locale>/<type>.{html,txt}.tmpl composing
+// using the embedded templates. See Templates.Composer.
+func Composer(fromName, fromAddr string) (notification.ComposeFunc, error) {
+	tmpls, err := LoadTemplates("")
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpls.Composer(fromName, fromAddr), nil
+}
+
+// Composer handles <locale>/<type>.{html,txt}.tmpl composing
+// where type is the notification.MessageType.
+// The locale is the available one that best matches the message preferred languages,
+// falling back to notification.DefaultLocale.
+// The plain text template also defines the "subject" template.
+// Always uses the latest loaded templates.
+// Uses notification.Message as data.
+func (t *Templates) Composer(fromName, fromAddr string) notification.ComposeFunc {
+	from := &mail.Address{Name: fromName, Address: fromAddr}
+
+	return func(ctx context.Context, email string, w io.Writer, v interface{}) error {
+		msg, ok := v.(notification.Message)
+		if !ok {
+			return fmt.Errorf("unexpected message type %T", v)
+		}
+
+		tmpl, err := t.mailTemplates().match(msg)
+		if err != nil {
+			return err
+		}
+
+		subject, html, plainText, err := tmpl.render(msg)
+		if err != nil {
+			return err
+		}
+
+		to := &mail.Address{Address: email}
+		err = buildMessage(w, from, to, subject, html, plainText)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// mailTemplates holds the localized templates of each message type.
+type mailTemplates map[notification.MessageType]*localizedTemplates
+
+type localizedTemplates struct {
+	// locales are sorted with notification.DefaultLocale first.
+	locales  []string
+	byLocale map[string]*mailTemplate
+}
+
+type mailTemplate struct {
+	msgType   notification.MessageType
+	html      *htmltemplate.Template
+	plainText *texttemplate.Template
+}
+
+// parseMailTemplates parses the templates of each message type
+// inside each locale directory in fsys.
+// Only the default locale must have templates for every message type.
+func parseMailTemplates(fsys fs.FS) (mailTemplates, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("could not read mail templates dir: %w", err)
+	}
+
+	tmpls := mailTemplates{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		locale := entry.Name()
+		if _, err := language.Parse(locale); err != nil {
+			return nil, fmt.Errorf("invalid mail templates locale %q: %w", locale, err)
+		}
+
+		for _, msgType := range notification.MailMessageTypes {
+			_, err := fs.Stat(fsys, path.Join(locale, string(msgType)+".html.tmpl"))
+			if errors.Is(err, fs.ErrNotExist) && locale != notification.DefaultLocale {
+				continue
+			}
+
+			tmpl, err := parseMailTemplate(fsys, locale, msgType)
+			if err != nil {
+				return nil, err
+			}
+
+			lt, ok := tmpls[msgType]
+			if !ok {
+				lt = &localizedTemplates{byLocale: map[string]*mailTemplate{}}
+				tmpls[msgType] = lt
+			}
+
+			lt.locales = append(lt.locales, locale)
+			lt.byLocale[locale] = tmpl
+		}
+	}
+
+	for _, msgType := range notification.MailMessageTypes {
+		lt, ok := tmpls[msgType]
+		if !ok {
+			return nil, fmt.Errorf("missing %q %s templates", notification.DefaultLocale, msgType)
+		}
+
+		sort.Slice(lt.locales, func(i, j int) bool {
+			if lt.locales[i] == notification.DefaultLocale {
+				return true
+			}
+			if lt.locales[j] == notification.DefaultLocale {
+				return false
+			}
+			return lt.locales[i] < lt.locales[j]
+		})
+	}
+
+	return tmpls, nil
+}
+
+func parseMailTemplate(fsys fs.FS, locale string, msgType notification.MessageType) (*mailTemplate, error) {
+	funcs := map[string]interface{}{
+		"human_duration": notification.HumanDurationIn(locale),
+	}
+
+	name := path.Join(locale, string(msgType))
+	b, err := fs.ReadFile(fsys, name+".html.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s %s html template file: %w", locale, msgType, err)
+	}
+
+	htmlTmpl, err := htmltemplate.New("mail/" + name + ".html").Funcs(funcs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s %s html template: %w", locale, msgType, err)
+	}
+
+	b, err = fs.ReadFile(fsys, name+".txt.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s %s plain text template file: %w", locale, msgType, err)
+	}
+
+	plainTextTmpl, err := texttemplate.New("mail/" + name + ".txt").Funcs(funcs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s %s plain text template: %w", locale, msgType, err)
+	}
+
+	if plainTextTmpl.Lookup("subject") == nil {
+		return nil, fmt.Errorf("%s %s plain text template does not define a subject", locale, msgType)
+	}
+
+	return &mailTemplate{msgType: msgType, html: htmlTmpl, plainText: plainTextTmpl}, nil
+}
+
+func (tmpls mailTemplates) match(msg notification.Message) (*mailTemplate, error) {
+	lt, ok := tmpls[msg.MessageType()]
+	if !ok {
+		return nil, fmt.Errorf("no mail templates for %q messages", msg.MessageType())
+	}
+
+	return lt.byLocale[notification.MatchLocale(lt.locales, msg.PreferredLanguages())], nil
+}
+
+func (tmpl *mailTemplate) render(msg notification.Message) (subject, html, plainText string, err error) {
+	subjectRenderer, htmlRenderer, plainTextRenderer := &strings.Builder{}, &bytes.Buffer{}, &bytes.Buffer{}
+	g := &errgroup.Group{}
+	g.Go(func() error {
+		err := tmpl.html.Execute(htmlRenderer, msg)
+		if err != nil {
+			return fmt.Errorf("could not render %s html template: %w", tmpl.msgType, err)
+		}
+
+		return nil
+	})
+	g.Go(func() error {
+		err := tmpl.plainText.Execute(plainTextRenderer, msg)
+		if err != nil {
+			return fmt.Errorf("could not render %s plain text template: %w", tmpl.msgType, err)
+		}
+
+		err = tmpl.plainText.ExecuteTemplate(subjectRenderer, "subject", msg)
+		if err != nil {
+			return fmt.Errorf("could not render %s subject template: %w", tmpl.msgType, err)
+		}
+
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		return "", "", "", err
+	}
+
+	// Keep the subject in a single line.
+	subject = strings.Join(strings.Fields(subjectRenderer.String()), " ")
+	return subject, htmlRenderer.String(), plainTextRenderer.String(), nil
+}
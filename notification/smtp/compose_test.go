@@ -0,0 +1,168 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/mail"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+func TestComposer(t *testing.T) {
+	composeFunc, err := Composer("Passwordless", "noreply@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		msg         notification.Message
+		wantSubject string
+		wantBody    string
+	}{
+		{
+			name:        "default",
+			msg:         testMagicLinkData(),
+			wantSubject: "Login to Golang Passwordless Demo",
+			wantBody:    "This link expires in 20 minutes.",
+		},
+		{
+			name:        "spanish",
+			msg:         withLanguages(testMagicLinkData(), "es-CL", "en"),
+			wantSubject: "Inicia sesión en Golang Passwordless Demo",
+			wantBody:    "Este enlace expira en 20 minutos.",
+		},
+		{
+			name:        "unavailable",
+			msg:         withLanguages(testMagicLinkData(), "fr"),
+			wantSubject: "Login to Golang Passwordless Demo",
+			wantBody:    "This link expires in 20 minutes.",
+		},
+		{
+			name: "welcome",
+			msg: notification.WelcomeData{
+				Origin:   notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org"}},
+				Username: "jane",
+			},
+			wantSubject: "Welcome to Golang Passwordless Demo",
+			wantBody:    "Welcome, jane",
+		},
+		{
+			name: "login alert",
+			msg: notification.LoginAlertData{
+				Origin:    notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org"}},
+				At:        time.Date(2021, time.March, 4, 15, 30, 0, 0, time.UTC),
+				Languages: []string{"es"},
+			},
+			wantSubject: "Nuevo inicio de sesión en Golang Passwordless Demo",
+			wantBody:    "el 04/03/2021 a las 15:30 UTC.",
+		},
+		{
+			name: "account change",
+			msg: notification.AccountChangeData{
+				Origin: notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org"}},
+				Change: notification.AccountChangePhoneNumber,
+			},
+			wantSubject: "Your Golang Passwordless Demo account changed",
+			wantBody:    "The phone number of your account at example.org was changed.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := composeFunc(context.Background(), "jane@example.org", buf, tt.msg)
+			if err != nil {
+				t.Fatalf("composeFunc() error = %v", err)
+			}
+
+			m, err := mail.ReadMessage(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subject, err := (&mime.WordDecoder{}).DecodeHeader(m.Header.Get("Subject"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", subject, tt.wantSubject)
+			}
+
+			b, err := io.ReadAll(m.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Quoted-printable soft line breaks.
+			body := strings.ReplaceAll(string(b), "=\r\n", "")
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body does not contain %q:\n%s", tt.wantBody, body)
+			}
+
+			if strings.Contains(body, "&amp;") {
+				t.Errorf("plain text body contains html escaped magic link:\n%s", body)
+			}
+		})
+	}
+}
+
+func Test_parseMailTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr string
+	}{
+		{
+			name: "missing default locale",
+			fsys: fstest.MapFS{
+				"es/magic-link.html.tmpl": {Data: []byte(`{{ .MagicLink }}`)},
+				"es/magic-link.txt.tmpl":  {Data: []byte(`{{ define "subject" }}Hola{{ end }}{{ .MagicLink }}`)},
+			},
+			wantErr: `missing "en"`,
+		},
+		{
+			name: "missing subject",
+			fsys: fstest.MapFS{
+				"en/magic-link.html.tmpl": {Data: []byte(`{{ .MagicLink }}`)},
+				"en/magic-link.txt.tmpl":  {Data: []byte(`{{ .MagicLink }}`)},
+			},
+			wantErr: "does not define a subject",
+		},
+		{
+			name: "missing default message type",
+			fsys: fstest.MapFS{
+				"en/magic-link.html.tmpl": {Data: []byte(`{{ .MagicLink }}`)},
+				"en/magic-link.txt.tmpl":  {Data: []byte(`{{ define "subject" }}Login{{ end }}{{ .MagicLink }}`)},
+			},
+			wantErr: "could not read en welcome html template file",
+		},
+		{
+			name: "invalid locale",
+			fsys: fstest.MapFS{
+				"not a locale/magic-link.html.tmpl": {Data: []byte(`{{ .MagicLink }}`)},
+			},
+			wantErr: "invalid mail templates locale",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseMailTemplates(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseMailTemplates() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func withLanguages(data notification.MagicLinkData, languages ...string) notification.MagicLinkData {
+	data.Languages = languages
+	return data
+}
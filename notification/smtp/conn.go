@@ -0,0 +1,219 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// conn is an SMTP client along its underlying network connection
+// so deadlines can be set on it.
+type conn struct {
+	netConn  net.Conn
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// watch interrupts the connection as soon as ctx is done,
+// either by its deadline or by cancellation.
+// The returned func must be called once done with the connection.
+// It reports whether ctx interrupted the connection.
+func (c *conn) watch(ctx context.Context) (stop func() bool) {
+	done := make(chan struct{})
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.netConn.SetDeadline(time.Now())
+			interrupted <- true
+		case <-done:
+			interrupted <- false
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		if <-interrupted {
+			return true
+		}
+
+		_ = c.netConn.SetDeadline(time.Time{})
+		return false
+	}
+}
+
+// quit politely ends the connection,
+// giving the server a little time to respond.
+func (c *conn) quit() {
+	_ = c.netConn.SetDeadline(time.Now().Add(time.Second))
+	if err := c.client.Quit(); err != nil {
+		c.close()
+	}
+}
+
+func (c *conn) close() {
+	_ = c.client.Close()
+}
+
+// conn returns an idle connection still alive
+// or dials a new one.
+func (s *Sender) conn(ctx context.Context) (*conn, error) {
+	for {
+		c, ok := s.pop()
+		if !ok {
+			break
+		}
+
+		if time.Since(c.lastUsed) > s.idleTimeout() {
+			go c.quit()
+			continue
+		}
+
+		stop := c.watch(ctx)
+		err := c.client.Reset()
+		if interrupted := stop(); interrupted {
+			c.close()
+			return nil, ctx.Err()
+		}
+
+		if err != nil {
+			c.close()
+			continue
+		}
+
+		return c, nil
+	}
+
+	return s.dial(ctx)
+}
+
+func (s *Sender) pop() (*conn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.idle)
+	if n == 0 {
+		return nil, false
+	}
+
+	c := s.idle[n-1]
+	s.idle[n-1] = nil
+	s.idle = s.idle[:n-1]
+	return c, true
+}
+
+// put keeps the connection alive for later use
+// or ends it if there are enough idle connections already.
+func (s *Sender) put(c *conn) {
+	c.lastUsed = time.Now()
+
+	s.mu.Lock()
+	if !s.closed && len(s.idle) < s.maxIdleConns() {
+		s.idle = append(s.idle, c)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	go c.quit()
+}
+
+func (s *Sender) dial(ctx context.Context) (*conn, error) {
+	// Marks when no idle connection could be reused.
+	trace.SpanFromContext(ctx).AddEvent("dial")
+
+	var (
+		netConn net.Conn
+		err     error
+	)
+	dialer := &net.Dialer{}
+	switch s.TLSMode {
+	case TLSModeImplicit:
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}
+		netConn, err = tlsDialer.DialContext(ctx, "tcp", s.addr)
+	case "", TLSModeStartTLS, TLSModeNone:
+		netConn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	default:
+		return nil, fmt.Errorf("unknown smtp tls mode %q", s.TLSMode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not dial smtp server: %w", err)
+	}
+
+	c := &conn{netConn: netConn}
+	stop := c.watch(ctx)
+	err = s.handshake(c)
+	if interrupted := stop(); interrupted && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = netConn.Close()
+		if ctx.Err() != nil && err != ctx.Err() {
+			err = fmt.Errorf("%v: %w", err, ctx.Err())
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// handshake greets the server, upgrades the connection with STARTTLS
+// if required and authenticates.
+func (s *Sender) handshake(c *conn) error {
+	client, err := smtp.NewClient(c.netConn, s.Host)
+	if err != nil {
+		return fmt.Errorf("could not create smtp client: %w", err)
+	}
+
+	c.client = client
+
+	if err := client.Hello("localhost"); err != nil {
+		return fmt.Errorf("could not send EHLO command: %w", err)
+	}
+
+	if s.TLSMode == "" || s.TLSMode == TLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server does not support STARTTLS")
+		}
+
+		if err := client.StartTLS(s.tlsConfig); err != nil {
+			return fmt.Errorf("could not starttls: %w", err)
+		}
+	}
+
+	if s.Username == "" {
+		return nil
+	}
+
+	auth, err := s.auth()
+	if err != nil {
+		return err
+	}
+
+	ok, mechanisms := client.Extension("AUTH")
+	if !ok || !containsFold(strings.Fields(mechanisms), string(s.authMechanism())) {
+		return fmt.Errorf("smtp server does not support %s auth", s.authMechanism())
+	}
+
+	if err := client.Auth(auth); err != nil {
+		return fmt.Errorf("could not smtp auth: %w", err)
+	}
+
+	return nil
+}
+
+func containsFold(ss []string, s string) bool {
+	for _, v := range ss {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
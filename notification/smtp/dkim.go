@@ -0,0 +1,85 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// DKIM signs outgoing messages so receivers can verify
+// they come from Domain. The public key must be published
+// as a TXT record at <Selector>._domainkey.<Domain>.
+type DKIM struct {
+	Domain   string
+	Selector string
+	// Signer is either an *rsa.PrivateKey or an ed25519.PrivateKey.
+	Signer crypto.Signer
+}
+
+// Sign returns the message with a DKIM-Signature header prepended.
+// Headers and body are canonicalized with the relaxed algorithm.
+func (d *DKIM) Sign(msg []byte) ([]byte, error) {
+	signed := &bytes.Buffer{}
+	err := dkim.Sign(signed, bytes.NewReader(msg), &dkim.SignOptions{
+		Domain:                 d.Domain,
+		Selector:               d.Selector,
+		Signer:                 d.Signer,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not dkim sign message: %w", err)
+	}
+
+	return signed.Bytes(), nil
+}
+
+// LoadDKIMKey reads a PEM encoded RSA or Ed25519 private key file.
+// Both PKCS #1 and PKCS #8 RSA keys are supported.
+func LoadDKIMKey(file string) (crypto.Signer, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read dkim private key file: %w", err)
+	}
+
+	return ParseDKIMKey(b)
+}
+
+// ParseDKIMKey parses a PEM encoded RSA or Ed25519 private key.
+func ParseDKIMKey(b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("could not parse dkim private key: no pem block found")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse dkim rsa private key: %w", err)
+		}
+
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse dkim private key: %w", err)
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported dkim private key type %T", key)
+}
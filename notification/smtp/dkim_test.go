@@ -0,0 +1,189 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+func TestDKIM_Sign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+	}{
+		{name: "rsa", signer: rsaKey},
+		{name: "ed25519", signer: ed25519Key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DKIM{Domain: "example.org", Selector: "mail", Signer: tt.signer}
+			msg := composeTestMessage(t)
+
+			signed, err := d.Sign(msg)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			if !bytes.HasPrefix(signed, []byte("DKIM-Signature: ")) {
+				t.Fatalf("signed message does not start with DKIM-Signature header:\n%s", signed)
+			}
+
+			verif := verifyDKIM(t, signed, d)
+			if verif.Err != nil {
+				t.Errorf("dkim verification error = %v", verif.Err)
+			}
+
+			if verif.Domain != "example.org" {
+				t.Errorf("dkim verification domain = %q, want %q", verif.Domain, "example.org")
+			}
+
+			tampered := bytes.Replace(signed, []byte("verify-magic-link"), []byte("evil-magic-link"), 1)
+			if verif := verifyDKIM(t, tampered, d); verif.Err == nil {
+				t.Error("dkim verification of tampered message error = nil")
+			}
+		})
+	}
+}
+
+func TestSender_Send_dkim(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &testServer{}
+	srv.start(t)
+	s := testSender(t, srv)
+	s.DKIM = &DKIM{Domain: "example.org", Selector: "mail", Signer: key}
+
+	err = s.Send(context.Background(), testMagicLinkData(), "jane@example.org")
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("server messages = %d, want 1", len(msgs))
+	}
+
+	// textproto dot reading leaves bare LFs.
+	data := strings.ReplaceAll(msgs[0].Data, "\n", "\r\n")
+	if verif := verifyDKIM(t, []byte(data), s.DKIM); verif.Err != nil {
+		t.Errorf("dkim verification error = %v", verif.Err)
+	}
+}
+
+func TestParseDKIMKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8 := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantErr bool
+	}{
+		{name: "rsa pkcs1", pem: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})},
+		{name: "rsa pkcs8", pem: pkcs8(rsaKey)},
+		{name: "ed25519 pkcs8", pem: pkcs8(ed25519Key)},
+		{name: "not pem", pem: []byte("nope"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDKIMKey(tt.pem)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDKIMKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func composeTestMessage(t *testing.T) []byte {
+	t.Helper()
+
+	composeFunc, err := Composer("Passwordless", "noreply@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = composeFunc(context.Background(), "jane@example.org", buf, testMagicLinkData())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// verifyDKIM verifies the only signature of msg
+// against the public key of d as if it was published in DNS.
+func verifyDKIM(t *testing.T, msg []byte, d *DKIM) *dkim.Verification {
+	t.Helper()
+
+	var record string
+	switch pub := d.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record = "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+	case ed25519.PublicKey:
+		record = "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)
+	}
+
+	verifs, err := dkim.VerifyWithOptions(bytes.NewReader(msg), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			if want := d.Selector + "._domainkey." + d.Domain; domain != want {
+				return nil, fmt.Errorf("unexpected dkim lookup %q, want %q", domain, want)
+			}
+
+			return []string{record}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not verify dkim: %v", err)
+	}
+
+	if len(verifs) != 1 {
+		t.Fatalf("dkim verifications = %d, want 1", len(verifs))
+	}
+
+	return verifs[0]
+}
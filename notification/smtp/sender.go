@@ -3,21 +3,83 @@ package smtp
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"html/template"
 	"io"
 	"net"
 	"net/mail"
-	"net/smtp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	mailutil "github.com/go-mail/mail"
-	"github.com/hako/durafmt"
 	"github.com/nicolasparada/go-passwordless-demo/notification"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/nicolasparada/go-passwordless-demo/notification/smtp")
+
+const (
+	defaultMaxIdleConns = 2
+	defaultIdleTimeout  = time.Second * 30
+)
+
+// TLSMode is how the connection with the SMTP server gets encrypted.
+type TLSMode string
+
+const (
+	// TLSModeStartTLS upgrades the connection with STARTTLS
+	// and fails if the server does not support it.
+	TLSModeStartTLS TLSMode = "starttls"
+	// TLSModeImplicit connects with TLS right away. Usually on port 465.
+	TLSModeImplicit TLSMode = "tls"
+	// TLSModeNone does not encrypt the connection.
+	// Only meant for local development servers.
+	TLSModeNone TLSMode = "none"
+)
+
+// ParseTLSMode parses a TLS mode. Empty defaults to TLSModeStartTLS.
+func ParseTLSMode(s string) (TLSMode, error) {
+	switch m := TLSMode(s); m {
+	case "":
+		return TLSModeStartTLS, nil
+	case TLSModeStartTLS, TLSModeImplicit, TLSModeNone:
+		return m, nil
+	}
+
+	return "", fmt.Errorf("unknown smtp tls mode %q", s)
+}
+
+// AuthMechanism is the SASL mechanism used to authenticate with the SMTP server.
+type AuthMechanism string
+
+const (
+	AuthPlain   AuthMechanism = "PLAIN"
+	AuthLogin   AuthMechanism = "LOGIN"
+	AuthCRAMMD5 AuthMechanism = "CRAM-MD5"
+)
+
+// ParseAuthMechanism parses an auth mechanism case-insensitively.
+// Empty defaults to AuthPlain.
+func ParseAuthMechanism(s string) (AuthMechanism, error) {
+	switch m := AuthMechanism(strings.ToUpper(s)); m {
+	case "":
+		return AuthPlain, nil
+	case AuthPlain, AuthLogin, AuthCRAMMD5:
+		return m, nil
+	}
+
+	return "", fmt.Errorf("unknown smtp auth mechanism %q", s)
+}
+
+// Sender sends emails through an SMTP server.
+// Connections are kept alive and reused between sends.
+// Call Close to close them.
 type Sender struct {
 	FromName    string
 	FromAddress string
@@ -25,36 +87,161 @@ type Sender struct {
 	Port        uint64
 	Username    string
 	Password    string
-	ComposeFunc func(ctx context.Context, to string, w io.Writer, data interface{}) error
+	// TLSMode defaults to TLSModeStartTLS.
+	TLSMode TLSMode
+	// RootCAs verifies the server certificate.
+	// Defaults to the system pool.
+	RootCAs *x509.CertPool
+	// AuthMechanism defaults to AuthPlain.
+	// No authentication is done without Username.
+	AuthMechanism AuthMechanism
+	// MaxIdleConns is the max number of connections kept alive. Defaults to 2.
+	MaxIdleConns int
+	// IdleTimeout is how long a connection is kept alive without use.
+	// Defaults to 30 seconds.
+	IdleTimeout time.Duration
+	// DKIM signs messages after composing them. Optional.
+	DKIM        *DKIM
+	ComposeFunc notification.ComposeFunc
 
 	once sync.Once
 
-	addr     string
-	auth     smtp.Auth
-	fromAddr *mail.Address
+	addr      string
+	tlsConfig *tls.Config
+	fromAddr  *mail.Address
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+func (s *Sender) init() {
+	s.addr = net.JoinHostPort(s.Host, strconv.FormatUint(s.Port, 10))
+	s.tlsConfig = &tls.Config{
+		ServerName: s.Host,
+		RootCAs:    s.RootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	s.fromAddr = &mail.Address{Name: s.FromName, Address: s.FromAddress}
 }
 
-func (s *Sender) Send(ctx context.Context, data notification.MagicLinkData, to string) error {
-	s.once.Do(func() {
-		s.addr = net.JoinHostPort(s.Host, strconv.FormatUint(s.Port, 10))
-		s.auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
-		s.fromAddr = &mail.Address{Name: s.FromName, Address: s.FromAddress}
-	})
+func (s *Sender) Send(ctx context.Context, msg notification.Message, to string) (err error) {
+	s.once.Do(s.init)
+
+	ctx, span := tracer.Start(ctx, "smtp.Send", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("notification.type", string(msg.MessageType())),
+		attribute.Bool("smtp.dkim", s.DKIM != nil),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	buf := &bytes.Buffer{}
+	err = s.ComposeFunc(ctx, to, buf, msg)
+	if err != nil {
+		return fmt.Errorf("could not compose %s message: %w", msg.MessageType(), err)
+	}
+
+	b := buf.Bytes()
+	if s.DKIM != nil {
+		b, err = s.DKIM.Sign(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	span.AddEvent("composed", trace.WithAttributes(attribute.Int("message.size", len(b))))
+
+	err = s.send(ctx, to, b)
+	if err != nil {
+		return fmt.Errorf("could not smtp send %s message: %w", msg.MessageType(), err)
+	}
+
+	return nil
+}
 
-	msg := &bytes.Buffer{}
-	err := s.ComposeFunc(ctx, to, msg, data)
+func (s *Sender) send(ctx context.Context, to string, msg []byte) (err error) {
+	c, err := s.conn(ctx)
 	if err != nil {
-		return fmt.Errorf("could not compose magic link message: %w", err)
+		return err
+	}
+
+	stop := c.watch(ctx)
+	defer func() {
+		// Once interrupted, the connection cannot be trusted anymore.
+		// Still, the message was accepted if there was no error.
+		interrupted := stop()
+		if err != nil || interrupted {
+			c.close()
+			if err != nil && ctx.Err() != nil {
+				err = fmt.Errorf("%v: %w", err, ctx.Err())
+			}
+			return
+		}
+
+		s.put(c)
+	}()
+
+	if err := c.client.Mail(s.fromAddr.Address); err != nil {
+		return fmt.Errorf("could not send MAIL command: %w", err)
 	}
 
-	err = smtp.SendMail(s.addr, s.auth, s.fromAddr.String(), []string{to}, msg.Bytes())
+	if err := c.client.Rcpt(to); err != nil {
+		return fmt.Errorf("could not send RCPT command: %w", err)
+	}
+
+	w, err := c.client.Data()
 	if err != nil {
-		return fmt.Errorf("could not smtp send magic link: %w", err)
+		return fmt.Errorf("could not send DATA command: %w", err)
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("could not write message: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("could not end message: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes all idle connections.
+// Sending after closing still works, but connections are not reused.
+func (s *Sender) Close() error {
+	s.mu.Lock()
+	idle := s.idle
+	s.idle = nil
+	s.closed = true
+	s.mu.Unlock()
+
+	for _, c := range idle {
+		c.quit()
 	}
 
 	return nil
 }
 
+func (s *Sender) maxIdleConns() int {
+	if s.MaxIdleConns > 0 {
+		return s.MaxIdleConns
+	}
+
+	return defaultMaxIdleConns
+}
+
+func (s *Sender) idleTimeout() time.Duration {
+	if s.IdleTimeout > 0 {
+		return s.IdleTimeout
+	}
+
+	return defaultIdleTimeout
+}
+
 func buildMessage(w io.Writer, from, to *mail.Address, subject, html, text string) error {
 	m := mailutil.NewMessage()
 	m.SetHeader("From", from.String())
@@ -70,9 +257,3 @@ func buildMessage(w io.Writer, from, to *mail.Address, subject, html, text strin
 
 	return nil
 }
-
-var tmplFuncs = template.FuncMap{
-	"human_duration": func(d time.Duration) string {
-		return durafmt.Parse(d).LimitFirstN(1).String()
-	},
-}
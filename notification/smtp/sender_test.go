@@ -0,0 +1,266 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+func TestSender_Send(t *testing.T) {
+	tests := []struct {
+		name      string
+		srv       *testServer
+		tlsMode   TLSMode
+		mechanism AuthMechanism
+	}{
+		{
+			name:      "starttls plain",
+			srv:       &testServer{username: "john", password: "secret"},
+			tlsMode:   TLSModeStartTLS,
+			mechanism: AuthPlain,
+		},
+		{
+			name:      "implicit tls login",
+			srv:       &testServer{implicitTLS: true, username: "john", password: "secret"},
+			tlsMode:   TLSModeImplicit,
+			mechanism: AuthLogin,
+		},
+		{
+			name:      "none cram md5",
+			srv:       &testServer{noStartTLS: true, username: "john", password: "secret"},
+			tlsMode:   TLSModeNone,
+			mechanism: AuthCRAMMD5,
+		},
+		{
+			name:    "no auth",
+			srv:     &testServer{},
+			tlsMode: TLSModeStartTLS,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.srv.start(t)
+			s := testSender(t, tt.srv)
+			s.Username = tt.srv.username
+			s.Password = tt.srv.password
+			s.TLSMode = tt.tlsMode
+			s.AuthMechanism = tt.mechanism
+
+			for _, to := range []string{"jane@example.org", "john@example.org"} {
+				err := s.Send(context.Background(), testMagicLinkData(), to)
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+
+			if got := tt.srv.Conns(); got != 1 {
+				t.Errorf("server connections = %d, want connection to be reused", got)
+			}
+
+			msgs := tt.srv.Messages()
+			if len(msgs) != 2 {
+				t.Fatalf("server messages = %d, want 2", len(msgs))
+			}
+
+			msg := msgs[1]
+			if msg.From != "noreply@example.org" {
+				t.Errorf("message from = %q, want %q", msg.From, "noreply@example.org")
+			}
+
+			if len(msg.To) != 1 || msg.To[0] != "john@example.org" {
+				t.Errorf("message to = %v, want [john@example.org]", msg.To)
+			}
+
+			if !strings.Contains(msg.Data, "Subject: ") {
+				t.Errorf("message data does not contain subject header:\n%s", msg.Data)
+			}
+		})
+	}
+}
+
+func TestSender_Send_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		srv     *testServer
+		setup   func(s *Sender)
+		wantErr string
+	}{
+		{
+			name:    "starttls unsupported",
+			srv:     &testServer{noStartTLS: true},
+			wantErr: "STARTTLS",
+		},
+		{
+			name: "untrusted certificate",
+			srv:  &testServer{},
+			setup: func(s *Sender) {
+				s.RootCAs = nil
+			},
+			wantErr: "certificate",
+		},
+		{
+			name: "invalid credentials",
+			srv:  &testServer{username: "john", password: "secret"},
+			setup: func(s *Sender) {
+				s.Username = "john"
+				s.Password = "wrong"
+				s.AuthMechanism = AuthLogin
+			},
+			wantErr: "535",
+		},
+		{
+			name: "unknown tls mode",
+			srv:  &testServer{},
+			setup: func(s *Sender) {
+				s.TLSMode = "ssl"
+			},
+			wantErr: "unknown smtp tls mode",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.srv.start(t)
+			s := testSender(t, tt.srv)
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+
+			err := s.Send(context.Background(), testMagicLinkData(), "jane@example.org")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Send() error = %v, want containing %q", err, tt.wantErr)
+			}
+
+			if got := len(tt.srv.Messages()); got != 0 {
+				t.Errorf("server messages = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestSender_Send_context(t *testing.T) {
+	t.Run("deadline", func(t *testing.T) {
+		srv := &testServer{stall: true}
+		srv.start(t)
+		s := testSender(t, srv)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+
+		start := time.Now()
+		err := s.Send(ctx, testMagicLinkData(), "jane@example.org")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Send() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Send() took %s, want it to honor the context deadline", elapsed)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		srv := &testServer{stall: true}
+		srv.start(t)
+		s := testSender(t, srv)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(time.Millisecond*100, cancel)
+
+		err := s.Send(ctx, testMagicLinkData(), "jane@example.org")
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Send() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestSender_Send_reconnect(t *testing.T) {
+	t.Run("closed by server", func(t *testing.T) {
+		srv := &testServer{closeAfterMessage: true}
+		srv.start(t)
+		s := testSender(t, srv)
+
+		for i := 0; i < 2; i++ {
+			err := s.Send(context.Background(), testMagicLinkData(), "jane@example.org")
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+		}
+
+		if got := srv.Conns(); got != 2 {
+			t.Errorf("server connections = %d, want 2", got)
+		}
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		srv := &testServer{}
+		srv.start(t)
+		s := testSender(t, srv)
+		s.IdleTimeout = time.Millisecond * 50
+
+		for i := 0; i < 2; i++ {
+			err := s.Send(context.Background(), testMagicLinkData(), "jane@example.org")
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			time.Sleep(time.Millisecond * 100)
+		}
+
+		if got := srv.Conns(); got != 2 {
+			t.Errorf("server connections = %d, want 2", got)
+		}
+	})
+}
+
+func TestParseAuthMechanism(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    AuthMechanism
+		wantErr bool
+	}{
+		{in: "", want: AuthPlain},
+		{in: "login", want: AuthLogin},
+		{in: "CRAM-MD5", want: AuthCRAMMD5},
+		{in: "XOAUTH2", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAuthMechanism(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAuthMechanism(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func testSender(t *testing.T, srv *testServer) *Sender {
+	t.Helper()
+
+	composeFunc, err := Composer("Passwordless", "noreply@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Sender{
+		FromName:    "Passwordless",
+		FromAddress: "noreply@example.org",
+		Host:        srv.Host,
+		Port:        srv.Port,
+		RootCAs:     srv.RootCAs,
+		ComposeFunc: composeFunc,
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+	return s
+}
+
+func testMagicLinkData() notification.MagicLinkData {
+	return notification.MagicLinkData{
+		Origin:    notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org"}},
+		TTL:       time.Minute * 20,
+		MagicLink: notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org", Path: "/api/verify-magic-link", RawQuery: "code=123"}},
+	}
+}
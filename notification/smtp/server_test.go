@@ -0,0 +1,281 @@
+package smtp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is a tiny in-process SMTP server
+// supporting just enough of the protocol to test Sender.
+type testServer struct {
+	Host string
+	Port uint64
+	// RootCAs trusts the server certificate.
+	RootCAs *x509.CertPool
+
+	implicitTLS       bool
+	noStartTLS        bool
+	stall             bool
+	closeAfterMessage bool
+	username          string
+	password          string
+
+	ln        net.Listener
+	tlsConfig *tls.Config
+
+	mu    sync.Mutex
+	conns int
+	msgs  []testMessage
+}
+
+type testMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func (srv *testServer) start(t *testing.T) {
+	t.Helper()
+
+	cert, pool := testCertificate(t)
+	srv.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.RootCAs = pool
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if srv.implicitTLS {
+		ln = tls.NewListener(ln, srv.tlsConfig)
+	}
+
+	srv.ln = ln
+	srv.Host = "127.0.0.1"
+	srv.Port = uint64(ln.Addr().(*net.TCPAddr).Port)
+
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		ln.Close()
+		wg.Wait()
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			srv.mu.Lock()
+			srv.conns++
+			srv.mu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer nc.Close()
+				srv.serve(nc)
+			}()
+		}
+	}()
+}
+
+func (srv *testServer) Conns() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.conns
+}
+
+func (srv *testServer) Messages() []testMessage {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]testMessage(nil), srv.msgs...)
+}
+
+func (srv *testServer) serve(nc net.Conn) {
+	_ = nc.SetDeadline(time.Now().Add(time.Second * 5))
+	if srv.stall {
+		_, _ = nc.Read(make([]byte, 1))
+		return
+	}
+
+	tp := textproto.NewConn(nc)
+	isTLS := srv.implicitTLS
+	authed := srv.username == ""
+	var msg testMessage
+
+	reply := func(format string, args ...interface{}) bool {
+		return tp.PrintfLine(format, args...) == nil
+	}
+
+	if !reply("220 localhost ESMTP test") {
+		return
+	}
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			verb, arg = line[:i], line[i+1:]
+		}
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250-localhost")
+			if !isTLS && !srv.noStartTLS {
+				_ = tp.PrintfLine("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN LOGIN CRAM-MD5")
+		case "STARTTLS":
+			reply("220 ready to start tls")
+			tlsConn := tls.Server(nc, srv.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			nc = tlsConn
+			tp = textproto.NewConn(nc)
+			isTLS = true
+		case "AUTH":
+			authed = srv.auth(tp, arg)
+			if authed {
+				reply("235 authenticated")
+			} else {
+				reply("535 invalid credentials")
+			}
+		case "MAIL":
+			if !authed {
+				reply("530 authentication required")
+				continue
+			}
+			msg = testMessage{From: trimAddr(arg)}
+			reply("250 ok")
+		case "RCPT":
+			msg.To = append(msg.To, trimAddr(arg))
+			reply("250 ok")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			b, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(b)
+			srv.mu.Lock()
+			srv.msgs = append(srv.msgs, msg)
+			srv.mu.Unlock()
+			reply("250 ok queued")
+			if srv.closeAfterMessage {
+				return
+			}
+		case "RSET":
+			msg = testMessage{}
+			reply("250 ok")
+		case "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+func (srv *testServer) auth(tp *textproto.Conn, arg string) bool {
+	mechanism, initial := arg, ""
+	if i := strings.IndexByte(arg, ' '); i != -1 {
+		mechanism, initial = arg[:i], arg[i+1:]
+	}
+
+	challenge := func(s string) string {
+		_ = tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(s)))
+		line, _ := tp.ReadLine()
+		b, _ := base64.StdEncoding.DecodeString(line)
+		return string(b)
+	}
+
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		b, _ := base64.StdEncoding.DecodeString(initial)
+		return string(b) == "\x00"+srv.username+"\x00"+srv.password
+	case "LOGIN":
+		username := challenge("Username:")
+		password := challenge("Password:")
+		return username == srv.username && password == srv.password
+	case "CRAM-MD5":
+		c := "<1234.5678@localhost>"
+		resp := challenge(c)
+		h := hmac.New(md5.New, []byte(srv.password))
+		h.Write([]byte(c))
+		return resp == srv.username+" "+hex.EncodeToString(h.Sum(nil))
+	}
+
+	return false
+}
+
+func trimAddr(arg string) string {
+	start, end := strings.IndexByte(arg, '<'), strings.IndexByte(arg, '>')
+	if start == -1 || end < start {
+		return arg
+	}
+
+	return arg[start+1 : end]
+}
+
+// testCertificate creates a self signed certificate for 127.0.0.1.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
@@ -0,0 +1,250 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+	"github.com/nicolasparada/go-passwordless-demo/web"
+)
+
+const templatesPollInterval = time.Second * 2
+
+// sampleMessages are used to validate templates before using them.
+var sampleMessages = map[notification.MessageType]notification.Message{
+	notification.MessageTypeMagicLink: notification.MagicLinkData{
+		Origin: sampleOrigin,
+		TTL:    time.Minute * 20,
+		MagicLink: notification.URL{URL: &url.URL{
+			Scheme:   "https",
+			Host:     "example.org",
+			Path:     "/api/verify-magic-link",
+			RawQuery: "email=jane%40example.org&code=00000000-0000-4000-8000-000000000000&redirect_uri=https%3A%2F%2Fexample.org",
+		}},
+	},
+	notification.MessageTypeWelcome: notification.WelcomeData{
+		Origin:   sampleOrigin,
+		Username: "jane",
+	},
+	notification.MessageTypeLoginAlert: notification.LoginAlertData{
+		Origin: sampleOrigin,
+		At:     time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC),
+	},
+	notification.MessageTypeAccountChange: notification.AccountChangeData{
+		Origin: sampleOrigin,
+		Change: notification.AccountChangeUsername,
+	},
+}
+
+var sampleOrigin = notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org"}}
+
+// Templates holds the mail templates of every locale.
+// It's safe for concurrent use, even while reloading.
+type Templates struct {
+	dir string
+
+	mu      sync.RWMutex
+	mail    mailTemplates
+	version string
+}
+
+// LoadTemplates loads the mail templates embedded at web/template/mail.
+// Files inside dir, if given, take precedence over the embedded ones
+// so emails can be rebranded without rebuilding.
+// dir follows the same <locale>/<name>.tmpl layout.
+// Templates of every locale are validated rendering sample data.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{dir: dir}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *Templates) load() error {
+	embedded, err := fs.Sub(web.Files, "template/mail")
+	if err != nil {
+		return fmt.Errorf("could not open mail templates dir: %w", err)
+	}
+
+	fsys := embedded
+	var version string
+	if t.dir != "" {
+		version, err = dirVersion(t.dir)
+		if err != nil {
+			return err
+		}
+
+		fsys = overlayFS{upper: os.DirFS(t.dir), lower: embedded}
+	}
+
+	mail, err := parseMailTemplates(fsys)
+	if err != nil {
+		return err
+	}
+
+	for msgType, lt := range mail {
+		for _, locale := range lt.locales {
+			subject, _, _, err := lt.byLocale[locale].render(sampleMessages[msgType])
+			if err != nil {
+				return fmt.Errorf("invalid %s %s templates: %w", locale, msgType, err)
+			}
+
+			if subject == "" {
+				return fmt.Errorf("invalid %s %s templates: empty subject", locale, msgType)
+			}
+		}
+	}
+
+	t.mu.Lock()
+	t.mail = mail
+	t.version = version
+	t.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the templates each time a file inside dir changes
+// until ctx is done. Invalid templates are logged and the previous ones kept.
+func (t *Templates) Watch(ctx context.Context, logger *log.Logger) {
+	if t.dir == "" {
+		return
+	}
+
+	ticker := time.NewTicker(templatesPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		t.reloadIfChanged(logger)
+	}
+}
+
+func (t *Templates) reloadIfChanged(logger *log.Logger) {
+	version, err := dirVersion(t.dir)
+	if err != nil {
+		logger.Printf("could not check mail templates for changes: %v\n", err)
+		return
+	}
+
+	t.mu.RLock()
+	changed := version != t.version
+	t.mu.RUnlock()
+
+	if !changed {
+		return
+	}
+
+	if err := t.load(); err != nil {
+		logger.Printf("could not reload mail templates, keeping previous ones: %v\n", err)
+		// Do not retry until the files change again.
+		t.mu.Lock()
+		t.version = version
+		t.mu.Unlock()
+		return
+	}
+
+	logger.Println("mail templates reloaded")
+}
+
+func (t *Templates) mailTemplates() mailTemplates {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.mail
+}
+
+// dirVersion summarizes the names, sizes and modification times
+// of the files inside dir, so it changes whenever any file does.
+func dirVersion(dir string) (string, error) {
+	var lines []string
+	err := fs.WalkDir(os.DirFS(dir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %d %d", name, info.Size(), info.ModTime().UnixNano()))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not walk mail templates dir: %w", err)
+	}
+
+	sort.Strings(lines)
+	return strings.Join(lines, "\n"), nil
+}
+
+// overlayFS reads files from upper, falling back to lower.
+// Directory listings are merged.
+type overlayFS struct {
+	upper, lower fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return o.lower.Open(name)
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, upperErr := fs.ReadDir(o.upper, name)
+	if upperErr != nil && !errors.Is(upperErr, fs.ErrNotExist) {
+		return nil, upperErr
+	}
+
+	lower, lowerErr := fs.ReadDir(o.lower, name)
+	if lowerErr != nil && !errors.Is(lowerErr, fs.ErrNotExist) {
+		return nil, lowerErr
+	}
+
+	if upperErr != nil && lowerErr != nil {
+		return nil, upperErr
+	}
+
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	for _, entry := range append(upper, lower...) {
+		if seen[entry.Name()] {
+			continue
+		}
+
+		seen[entry.Name()] = true
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,138 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	t.Run("override", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "en/magic-link.txt.tmpl", `{{ define "subject" }}Login to ACME{{ end }}{{ .MagicLink }}`)
+
+		tmpls, err := LoadTemplates(dir)
+		if err != nil {
+			t.Fatalf("LoadTemplates() error = %v", err)
+		}
+
+		composeFunc := tmpls.Composer("ACME", "noreply@example.org")
+		if got := composeSubject(t, composeFunc, nil); got != "Login to ACME" {
+			t.Errorf("subject = %q, want overridden one", got)
+		}
+
+		// Not overridden locales are still embedded.
+		if got := composeSubject(t, composeFunc, []string{"es"}); got != "Inicia sesión en Golang Passwordless Demo" {
+			t.Errorf("es subject = %q, want embedded one", got)
+		}
+	})
+
+	t.Run("new locale", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "pt/magic-link.html.tmpl", `<a href="{{ .MagicLink }}">Entrar</a>`)
+		writeTemplate(t, dir, "pt/magic-link.txt.tmpl", `{{ define "subject" }}Entrar{{ end }}{{ .MagicLink }}`)
+
+		tmpls, err := LoadTemplates(dir)
+		if err != nil {
+			t.Fatalf("LoadTemplates() error = %v", err)
+		}
+
+		composeFunc := tmpls.Composer("ACME", "noreply@example.org")
+		if got := composeSubject(t, composeFunc, []string{"pt-BR"}); got != "Entrar" {
+			t.Errorf("subject = %q, want %q", got, "Entrar")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "en/magic-link.html.tmpl", `{{ .Missing }}`)
+
+		_, err := LoadTemplates(dir)
+		if err == nil || !strings.Contains(err.Error(), "invalid en magic-link templates") {
+			t.Errorf("LoadTemplates() error = %v, want sample render error", err)
+		}
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		_, err := LoadTemplates(filepath.Join(t.TempDir(), "missing"))
+		if err == nil {
+			t.Error("LoadTemplates() error = nil, want missing dir error")
+		}
+	})
+}
+
+func TestTemplates_reloadIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "en/magic-link.txt.tmpl", `{{ define "subject" }}Before{{ end }}{{ .MagicLink }}`)
+
+	tmpls, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+
+	logs := &bytes.Buffer{}
+	logger := log.New(logs, "", 0)
+	composeFunc := tmpls.Composer("ACME", "noreply@example.org")
+
+	writeTemplate(t, dir, "en/magic-link.txt.tmpl", `{{ define "subject" }}After{{ end }}{{ .MagicLink }}`)
+	tmpls.reloadIfChanged(logger)
+	if got := composeSubject(t, composeFunc, nil); got != "After" {
+		t.Errorf("subject = %q, want reloaded one", got)
+	}
+
+	writeTemplate(t, dir, "en/magic-link.txt.tmpl", `{{ .MagicLink }}`)
+	tmpls.reloadIfChanged(logger)
+	if got := composeSubject(t, composeFunc, nil); got != "After" {
+		t.Errorf("subject = %q, want previous one to be kept", got)
+	}
+
+	if !strings.Contains(logs.String(), "keeping previous ones") {
+		t.Errorf("logs = %q, want reload error", logs)
+	}
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	name = filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func composeSubject(t *testing.T, composeFunc notification.ComposeFunc, languages []string) string {
+	t.Helper()
+
+	data := testMagicLinkData()
+	data.Languages = languages
+
+	buf := &bytes.Buffer{}
+	if err := composeFunc(context.Background(), "jane@example.org", buf, data); err != nil {
+		t.Fatalf("composeFunc() error = %v", err)
+	}
+
+	m, err := mail.ReadMessage(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := (&mime.WordDecoder{}).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return subject
+}
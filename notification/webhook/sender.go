@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+const (
+	// SignatureHeader holds "t=<unix timestamp>,v1=<hex HMAC-SHA256>"
+	// where the HMAC is computed over "<timestamp>.<body>" with the shared secret.
+	SignatureHeader = "X-Passwordless-Signature"
+	// DeliveryHeader holds an ID that stays the same across retries
+	// of a single delivery, so receivers can ignore duplicates.
+	DeliveryHeader = "X-Passwordless-Delivery"
+
+	defaultMaxRetries = 2
+	defaultMinBackoff = time.Millisecond * 500
+)
+
+// Sender delivers notifications to a deployment's own system
+// by POSTing them as JSON to URL.
+// Requests are signed with Secret. See SignatureHeader.
+// Network errors, 429 and 5xx responses are retried.
+type Sender struct {
+	URL    string
+	Secret string
+	Client *http.Client
+	// MaxRetries after the first attempt. Defaults to 2 when nil.
+	// Zero disables them, like when the caller already retries.
+	MaxRetries *int
+	// MinBackoff is the delay before the first retry.
+	// It doubles on each retry. Defaults to 500ms.
+	MinBackoff time.Duration
+}
+
+// Payload is the JSON body POSTed to the webhook URL.
+type Payload struct {
+	Type notification.MessageType `json:"type"`
+	To   string                   `json:"to"`
+	Data notification.Message     `json:"data"`
+}
+
+type retryableError struct {
+	err error
+}
+
+func (err retryableError) Error() string { return err.err.Error() }
+func (err retryableError) Unwrap() error { return err.err }
+
+func (s *Sender) Send(ctx context.Context, msg notification.Message, to string) error {
+	body, err := json.Marshal(Payload{Type: msg.MessageType(), To: to, Data: msg})
+	if err != nil {
+		return fmt.Errorf("could not json marshal webhook payload: %w", err)
+	}
+
+	deliveryID, err := genDeliveryID()
+	if err != nil {
+		return err
+	}
+
+	backoff := s.minBackoff()
+	for retries := 0; ; retries++ {
+		err = s.post(ctx, deliveryID, body)
+		var retryable retryableError
+		if err == nil || !errors.As(err, &retryable) || retries == s.maxRetries() {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v: %w", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+func (s *Sender) post(ctx context.Context, deliveryID string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Signature(s.Secret, time.Now(), body))
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("could not do webhook request: %w", err)
+		}
+
+		return retryableError{fmt.Errorf("could not do webhook request: %w", err)}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(b))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return retryableError{err}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Signature computes the SignatureHeader value of body sent at t.
+func Signature(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + sign(secret, ts, body)
+}
+
+// VerifySignature checks a SignatureHeader value against body.
+// Signatures older than maxAge are rejected to prevent replays.
+func VerifySignature(secret, header string, body []byte, maxAge time.Duration) error {
+	var ts, v1 string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			v1 = kv[1]
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || v1 == "" {
+		return errors.New("malformed webhook signature")
+	}
+
+	if time.Since(time.Unix(unix, 0)) > maxAge {
+		return errors.New("webhook signature expired")
+	}
+
+	if !hmac.Equal([]byte(v1), []byte(sign(secret, ts, body))) {
+		return errors.New("invalid webhook signature")
+	}
+
+	return nil
+}
+
+func sign(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func genDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("could not generate webhook delivery id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func (s *Sender) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+
+	return defaultClient
+}
+
+func (s *Sender) maxRetries() int {
+	if s.MaxRetries != nil {
+		return *s.MaxRetries
+	}
+
+	return defaultMaxRetries
+}
+
+func (s *Sender) minBackoff() time.Duration {
+	if s.MinBackoff > 0 {
+		return s.MinBackoff
+	}
+
+	return defaultMinBackoff
+}
+
+var defaultClient = &http.Client{Timeout: time.Second * 10}
@@ -0,0 +1,208 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+func TestSender_Send(t *testing.T) {
+	data := notification.MagicLinkData{
+		Origin:    notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org"}},
+		TTL:       time.Minute * 20,
+		MagicLink: notification.URL{URL: &url.URL{Scheme: "https", Host: "example.org", Path: "/api/verify-magic-link", RawQuery: "code=123"}},
+		Languages: []string{"es"},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		var got struct {
+			Type notification.MessageType
+			To   string
+			Data notification.MagicLinkData
+		}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				t.Errorf("method = %s, want POST", r.Method)
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("could not read request body: %v", err)
+			}
+
+			if err := VerifySignature("secret", r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+				t.Errorf("VerifySignature() error = %v", err)
+			}
+
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Errorf("could not decode request body: %v", err)
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		s := &Sender{URL: srv.URL, Secret: "secret", Client: srv.Client()}
+		err := s.Send(context.Background(), data, "jane@example.org")
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+
+		if got.Type != "magic-link" || got.To != "jane@example.org" {
+			t.Errorf("Send() payload = %+v", got)
+		}
+
+		if got.Data.MagicLink.String() != data.MagicLink.String() || got.Data.TTL != data.TTL || len(got.Data.Languages) != 1 {
+			t.Errorf("Send() payload data = %+v, want %+v", got.Data, data)
+		}
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		var (
+			mu          sync.Mutex
+			deliveryIDs []string
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			deliveryIDs = append(deliveryIDs, r.Header.Get(DeliveryHeader))
+			attempts := len(deliveryIDs)
+			mu.Unlock()
+
+			if attempts < 3 {
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		s := &Sender{URL: srv.URL, Client: srv.Client(), MinBackoff: time.Millisecond}
+		err := s.Send(context.Background(), data, "jane@example.org")
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+
+		if len(deliveryIDs) != 3 {
+			t.Fatalf("attempts = %d, want 3", len(deliveryIDs))
+		}
+
+		if deliveryIDs[0] == "" || deliveryIDs[0] != deliveryIDs[1] || deliveryIDs[1] != deliveryIDs[2] {
+			t.Errorf("delivery ids = %v, want the same non empty id on every attempt", deliveryIDs)
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		attempts := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			http.Error(w, "down", http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		s := &Sender{URL: srv.URL, Client: srv.Client(), MaxRetries: intPtr(1), MinBackoff: time.Millisecond}
+		err := s.Send(context.Background(), data, "jane@example.org")
+		if err == nil || !strings.Contains(err.Error(), "502") {
+			t.Errorf("Send() error = %v, want 502 error", err)
+		}
+
+		if attempts != 2 {
+			t.Errorf("attempts = %d, want 2", attempts)
+		}
+	})
+
+	t.Run("retries disabled", func(t *testing.T) {
+		attempts := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			http.Error(w, "down", http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		s := &Sender{URL: srv.URL, Client: srv.Client(), MaxRetries: intPtr(0), MinBackoff: time.Millisecond}
+		err := s.Send(context.Background(), data, "jane@example.org")
+		if err == nil || !strings.Contains(err.Error(), "502") {
+			t.Errorf("Send() error = %v, want 502 error", err)
+		}
+
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		attempts := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			http.Error(w, "unknown recipient", http.StatusUnprocessableEntity)
+		}))
+		defer srv.Close()
+
+		s := &Sender{URL: srv.URL, Client: srv.Client(), MinBackoff: time.Millisecond}
+		err := s.Send(context.Background(), data, "jane@example.org")
+		if err == nil || !strings.Contains(err.Error(), "unknown recipient") {
+			t.Errorf("Send() error = %v, want webhook error", err)
+		}
+
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want client errors to not be retried", attempts)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+
+		s := &Sender{URL: srv.URL, Client: srv.Client(), MinBackoff: time.Millisecond}
+		err := s.Send(ctx, data, "jane@example.org")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Send() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"type":"magic-link"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{name: "ok", header: Signature("secret", now, body)},
+		{name: "wrong secret", header: Signature("other", now, body), wantErr: true},
+		{name: "expired", header: Signature("secret", now.Add(-time.Hour), body), wantErr: true},
+		{name: "malformed", header: "nope", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature("secret", tt.header, body, time.Minute*5)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
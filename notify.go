@@ -0,0 +1,82 @@
+package passwordless
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+type NotificationSender interface {
+	Send(ctx context.Context, msg notification.Message, to string) error
+}
+
+// queueNotification stores an outbox message to deliver msg through channel.
+// Call it inside the transaction of the change it notifies about
+// and wake the outbox dispatcher up after committing.
+func (svc *Service) queueNotification(ctx context.Context, channel OutboxChannel, to string, msg notification.Message) (OutboxMessage, error) {
+	payload, err := notification.MarshalMessage(msg)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+
+	return svc.Repository.StoreOutboxMessage(ctx, channel, to, payload, traceParent(ctx))
+}
+
+// notifyUser queues msg to the user email unless it's suppressed.
+func (svc *Service) notifyUser(ctx context.Context, u User, msg notification.Message) error {
+	err := svc.checkSuppression(ctx, u.Email)
+	if err == ErrEmailSuppressed {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.queueNotification(ctx, OutboxChannelEmail, u.Email, msg)
+	if err != nil {
+		return fmt.Errorf("could not queue %s notification: %w", msg.MessageType(), err)
+	}
+
+	return nil
+}
+
+func (svc *Service) welcomeData(ctx context.Context, u User) notification.WelcomeData {
+	return notification.WelcomeData{
+		Origin:    notification.URL{URL: svc.Origin},
+		Username:  u.Username,
+		Languages: preferredLanguages(ctx, u),
+	}
+}
+
+func (svc *Service) loginAlertData(ctx context.Context, u User, at time.Time) notification.LoginAlertData {
+	if u.TimeZone != nil {
+		if loc, err := time.LoadLocation(*u.TimeZone); err == nil {
+			at = at.In(loc)
+		}
+	}
+
+	return notification.LoginAlertData{
+		Origin:    notification.URL{URL: svc.Origin},
+		At:        at,
+		Languages: preferredLanguages(ctx, u),
+	}
+}
+
+func (svc *Service) accountChangeData(ctx context.Context, u User, change notification.AccountChange) notification.AccountChangeData {
+	return notification.AccountChangeData{
+		Origin:    notification.URL{URL: svc.Origin},
+		Change:    change,
+		Languages: preferredLanguages(ctx, u),
+	}
+}
+
+func strPtrEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -0,0 +1,57 @@
+package passwordless
+
+import (
+	"net/url"
+	"strings"
+)
+
+// IsTrustedOrigin tells whether the given origin, like "https://example.org",
+// is Origin itself or one of TrustedOrigins.
+// Redirect URIs and cross-origin requests are only allowed from them.
+func (svc *Service) IsTrustedOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return false
+	}
+
+	return svc.isTrustedURL(u)
+}
+
+// isTrustedURL tells whether the scheme, host and port of u
+// match Origin or one of TrustedOrigins.
+func (svc *Service) isTrustedURL(u *url.URL) bool {
+	want := normalizeOrigin(u)
+	if want == normalizeOrigin(svc.Origin) {
+		return true
+	}
+
+	for _, s := range svc.TrustedOrigins {
+		trusted, err := url.Parse(s)
+		if err != nil {
+			continue
+		}
+
+		if normalizeOrigin(trusted) == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeOrigin returns the scheme://host[:port] of u,
+// lowercased and without default ports.
+func normalizeOrigin(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if scheme == "http" && port == "80" || scheme == "https" && port == "443" {
+		port = ""
+	}
+
+	if port != "" {
+		host += ":" + port
+	}
+
+	return scheme + "://" + host
+}
@@ -0,0 +1,65 @@
+package passwordless
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestService_IsTrustedOrigin(t *testing.T) {
+	svc := &Service{
+		Origin:         &url.URL{Scheme: "https", Host: "example.org"},
+		TrustedOrigins: []string{"https://app.example.org", "http://localhost:8080"},
+	}
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{name: "own", origin: "https://example.org", want: true},
+		{name: "own over http", origin: "http://example.org"},
+		{name: "own other port", origin: "https://example.org:8443"},
+		{name: "trusted", origin: "https://app.example.org", want: true},
+		{name: "default port", origin: "https://app.example.org:443", want: true},
+		{name: "case insensitive", origin: "HTTPS://App.Example.org", want: true},
+		{name: "trusted with port", origin: "http://localhost:8080", want: true},
+		{name: "other port", origin: "http://localhost:3000"},
+		{name: "other scheme", origin: "http://app.example.org"},
+		{name: "untrusted", origin: "https://evil.example"},
+		{name: "suffix", origin: "https://app.example.org.evil.example"},
+		{name: "null", origin: "null"},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := svc.IsTrustedOrigin(tt.origin); got != tt.want {
+				t.Errorf("IsTrustedOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_ValidateRedirectURI(t *testing.T) {
+	svc := &Service{
+		Origin:         &url.URL{Scheme: "https", Host: "example.org"},
+		TrustedOrigins: []string{"https://app.example.org"},
+	}
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr error
+	}{
+		{name: "own", uri: "https://example.org/callback"},
+		{name: "own host over http", uri: "http://example.org/callback"},
+		{name: "trusted", uri: "https://app.example.org/callback"},
+		{name: "trusted over http", uri: "http://app.example.org/callback", wantErr: ErrUntrustedRedirectURI},
+		{name: "untrusted", uri: "https://evil.example/callback", wantErr: ErrUntrustedRedirectURI},
+		{name: "relative", uri: "/callback", wantErr: ErrInvalidRedirectURI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.ValidateRedirectURI(tt.uri); err != tt.wantErr {
+				t.Errorf("ValidateRedirectURI(%q) error = %v, want %v", tt.uri, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package passwordless
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/nicolasparada/go-passwordless-demo")
+
+const (
+	defaultOutboxMaxAttempts = 8
+	outboxPollInterval       = time.Second
+	outboxBatchSize          = 10
+	outboxLease              = time.Minute
+	outboxSendTimeout        = time.Second * 30
+	outboxMinBackoff         = time.Second * 5
+	outboxMaxBackoff         = time.Hour
+	traceParentKey           = "traceparent"
+)
+
+type OutboxChannel string
+
+const (
+	OutboxChannelEmail OutboxChannel = "email"
+	OutboxChannelSMS   OutboxChannel = "sms"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusDelivered OutboxStatus = "delivered"
+	// OutboxStatusDead means delivery was given up after too many attempts.
+	OutboxStatusDead OutboxStatus = "dead"
+)
+
+// OutboxMessage is a notification waiting to be delivered,
+// stored in the same transaction as the data it refers to.
+// Recipient, payload and errors are kept private since
+// anyone knowing the ID can check the delivery status.
+type OutboxMessage struct {
+	// ID is empty for SMS logins, see Service.SendMagicLink.
+	ID            string          `json:"id,omitempty"`
+	Channel       OutboxChannel   `json:"channel"`
+	To            string          `json:"-"`
+	Payload       json.RawMessage `json:"-"`
+	Status        OutboxStatus    `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     *string         `json:"-"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	DeliveredAt   *time.Time      `json:"deliveredAt"`
+	// TraceParent is the W3C traceparent of the request that queued the message.
+	TraceParent *string `json:"-"`
+}
+
+// OutboxMessage returns the delivery status of an outbox message.
+func (svc *Service) OutboxMessage(ctx context.Context, id string) (OutboxMessage, error) {
+	if !reUUID4.MatchString(id) {
+		return OutboxMessage{}, ErrInvalidOutboxMessageID
+	}
+
+	return svc.Repository.OutboxMessage(ctx, id)
+}
+
+// anonymousOutboxMessage hides the ID of msg,
+// and what only a stored message would have,
+// so its delivery cannot be checked.
+func anonymousOutboxMessage(msg OutboxMessage) OutboxMessage {
+	return OutboxMessage{
+		Channel:       msg.Channel,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: msg.NextAttemptAt,
+		CreatedAt:     msg.CreatedAt,
+	}
+}
+
+func (svc *Service) outboxSender(channel OutboxChannel) (NotificationSender, bool) {
+	switch channel {
+	case OutboxChannelEmail:
+		return svc.EmailSender, svc.EmailSender != nil
+	case OutboxChannelSMS:
+		return svc.SMSSender, svc.SMSSender != nil
+	}
+
+	return nil, false
+}
+
+func (svc *Service) outboxWakeup() chan struct{} {
+	svc.outboxOnce.Do(func() {
+		svc.outboxWake = make(chan struct{}, 1)
+	})
+	return svc.outboxWake
+}
+
+// wakeOutboxDispatcher makes a running dispatcher
+// check for new messages right away instead of waiting for its next poll.
+func (svc *Service) wakeOutboxDispatcher() {
+	select {
+	case svc.outboxWakeup() <- struct{}{}:
+	default:
+	}
+}
+
+// RunOutboxDispatcher delivers pending outbox messages until ctx is done.
+// Failed deliveries are retried with exponential backoff
+// and dead-lettered after OutboxMaxAttempts.
+// Many dispatchers can run at the same time, even on different servers,
+// since messages are leased before delivering them.
+func (svc *Service) RunOutboxDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		svc.dispatchOutbox(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-svc.outboxWakeup():
+		}
+	}
+}
+
+func (svc *Service) dispatchOutbox(ctx context.Context) {
+	for ctx.Err() == nil {
+		msgs, err := svc.Repository.ClaimOutboxMessages(ctx, outboxBatchSize, outboxLease)
+		if err != nil {
+			if ctx.Err() == nil {
+				svc.Logger.Printf("could not claim outbox messages: %v\n", err)
+			}
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, msg := range msgs {
+			wg.Add(1)
+			go func(msg OutboxMessage) {
+				defer wg.Done()
+				svc.dispatchOutboxMessage(ctx, msg)
+			}(msg)
+		}
+		wg.Wait()
+
+		if len(msgs) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func (svc *Service) dispatchOutboxMessage(ctx context.Context, msg OutboxMessage) {
+	// Deliveries continue the trace of the request that queued them.
+	if msg.TraceParent != nil {
+		ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{traceParentKey: *msg.TraceParent})
+	}
+
+	ctx, span := tracer.Start(ctx, "outbox.deliver", trace.WithAttributes(
+		attribute.String("outbox.message_id", msg.ID),
+		attribute.String("outbox.channel", string(msg.Channel)),
+		attribute.Int("outbox.attempt", msg.Attempts),
+	))
+	defer span.End()
+
+	// Claims count as attempts, so this only happens
+	// when previous ones never finished, like if sending crashed the process.
+	if msg.Attempts > svc.outboxMaxAttempts() {
+		svc.Logger.Printf("giving up outbox message %s after %d unfinished attempts\n", msg.ID, msg.Attempts-1)
+		err := svc.Repository.DeadLetterOutboxMessage(ctx, msg.ID, "too many unfinished attempts")
+		if err != nil {
+			svc.Logger.Printf("could not dead letter outbox message %s: %v\n", msg.ID, err)
+		}
+		return
+	}
+
+	err := svc.deliverOutboxMessage(ctx, msg)
+	if err == nil {
+		err = svc.Repository.MarkOutboxMessageDelivered(ctx, msg.ID)
+		if err != nil {
+			svc.Logger.Printf("could not mark outbox message %s as delivered: %v\n", msg.ID, err)
+		}
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	// Leave the message leased so it's retried once the lease expires.
+	if ctx.Err() != nil {
+		return
+	}
+
+	if msg.Attempts >= svc.outboxMaxAttempts() {
+		svc.Logger.Printf("giving up outbox message %s after %d attempts: %v\n", msg.ID, msg.Attempts, err)
+		err = svc.Repository.DeadLetterOutboxMessage(ctx, msg.ID, err.Error())
+		if err != nil {
+			svc.Logger.Printf("could not dead letter outbox message %s: %v\n", msg.ID, err)
+		}
+		return
+	}
+
+	delay := outboxBackoff(msg.Attempts)
+	svc.Logger.Printf("could not deliver outbox message %s, retrying in %s: %v\n", msg.ID, delay, err)
+	err = svc.Repository.RetryOutboxMessage(ctx, msg.ID, err.Error(), delay)
+	if err != nil {
+		svc.Logger.Printf("could not schedule outbox message %s retry: %v\n", msg.ID, err)
+	}
+}
+
+func (svc *Service) deliverOutboxMessage(ctx context.Context, msg OutboxMessage) error {
+	sender, ok := svc.outboxSender(msg.Channel)
+	if !ok {
+		return fmt.Errorf("no sender for outbox channel %q", msg.Channel)
+	}
+
+	data, err := notification.UnmarshalMessage(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("could not decode outbox message payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, outboxSendTimeout)
+	defer cancel()
+
+	return sender.Send(ctx, data, msg.To)
+}
+
+// traceParent returns the W3C traceparent of the span in ctx, if any.
+func traceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get(traceParentKey)
+}
+
+func (svc *Service) outboxMaxAttempts() int {
+	if svc.OutboxMaxAttempts > 0 {
+		return svc.OutboxMaxAttempts
+	}
+
+	return defaultOutboxMaxAttempts
+}
+
+// outboxBackoff returns the delay before the next delivery attempt.
+// It doubles on each attempt, give or take 10% to spread retries.
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxMaxBackoff
+	if attempts < 20 {
+		d = outboxMinBackoff << uint(attempts-1)
+	}
+	if d > outboxMaxBackoff {
+		d = outboxMaxBackoff
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(d) / 5))
+	return d - d/10 + jitter
+}
@@ -0,0 +1,145 @@
+package passwordless
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func Test_outboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: outboxMinBackoff},
+		{attempts: 2, want: outboxMinBackoff * 2},
+		{attempts: 3, want: outboxMinBackoff * 4},
+		{attempts: 15, want: outboxMaxBackoff},
+		{attempts: 100, want: outboxMaxBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			got := outboxBackoff(tt.attempts)
+			if got < tt.want-tt.want/10 || got > tt.want+tt.want/10 {
+				t.Fatalf("outboxBackoff(%d) = %s, want %s give or take 10%%", tt.attempts, got, tt.want)
+			}
+		}
+	}
+}
+
+func Test_traceParent(t *testing.T) {
+	if got := traceParent(context.Background()); got != "" {
+		t.Errorf("traceParent() without span = %q, want empty", got)
+	}
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	sc := span.SpanContext()
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if got := traceParent(ctx); got != want {
+		t.Errorf("traceParent() = %q, want %q", got, want)
+	}
+}
+
+func TestService_dispatchOutbox(t *testing.T) {
+	tests := []struct {
+		name string
+		// prevAttempts were claimed before, but never finished.
+		prevAttempts int
+		failures     int
+		rounds       int
+		wantCalls    []string
+		wantStatus   OutboxStatus
+		wantSent     int
+	}{
+		{
+			name:       "delivered",
+			rounds:     1,
+			wantCalls:  []string{"MarkOutboxMessageDelivered"},
+			wantStatus: OutboxStatusDelivered,
+			wantSent:   1,
+		},
+		{
+			name:       "retried",
+			failures:   1,
+			rounds:     1,
+			wantCalls:  []string{"RetryOutboxMessage"},
+			wantStatus: OutboxStatusPending,
+		},
+		{
+			name:       "delivered after retries",
+			failures:   2,
+			rounds:     3,
+			wantCalls:  []string{"RetryOutboxMessage", "RetryOutboxMessage", "MarkOutboxMessageDelivered"},
+			wantStatus: OutboxStatusDelivered,
+			wantSent:   1,
+		},
+		{
+			name:       "dead after max attempts",
+			failures:   3,
+			rounds:     3,
+			wantCalls:  []string{"RetryOutboxMessage", "RetryOutboxMessage", "DeadLetterOutboxMessage"},
+			wantStatus: OutboxStatusDead,
+		},
+		{
+			name:         "too many unfinished attempts",
+			prevAttempts: 3,
+			rounds:       1,
+			wantCalls:    []string{"DeadLetterOutboxMessage"},
+			wantStatus:   OutboxStatusDead,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepo()
+			sender := &fakeSender{failures: tt.failures}
+			svc := newTestService(t, repo)
+			svc.EmailSender = sender
+			svc.OutboxMaxAttempts = 3
+
+			ctx := context.Background()
+			msg, err := svc.queueNotification(ctx, OutboxChannelEmail, "john@example.org", notification.WelcomeData{Username: "john"})
+			if err != nil {
+				t.Fatalf("queueNotification() error = %v", err)
+			}
+
+			repo.outbox[msg.ID].Attempts = tt.prevAttempts
+			for i := 0; i < tt.rounds; i++ {
+				// Skip the backoff.
+				repo.outbox[msg.ID].NextAttemptAt = time.Now()
+				svc.dispatchOutbox(ctx)
+			}
+
+			var wantCalls []string
+			for _, method := range tt.wantCalls {
+				wantCalls = append(wantCalls, method+" "+msg.ID)
+			}
+			if !reflect.DeepEqual(repo.calls, wantCalls) {
+				t.Errorf("calls = %q, want %q", repo.calls, wantCalls)
+			}
+
+			got, err := repo.OutboxMessage(ctx, msg.ID)
+			if err != nil {
+				t.Fatalf("OutboxMessage() error = %v", err)
+			}
+
+			if got.Status != tt.wantStatus {
+				t.Errorf("OutboxMessage() status = %s, want %s", got.Status, tt.wantStatus)
+			}
+
+			if len(sender.sent) != tt.wantSent {
+				t.Errorf("sent = %d, want %d", len(sender.sent), tt.wantSent)
+			}
+
+			if delivered := got.Status == OutboxStatusDelivered; delivered == bytes.Contains(got.Payload, []byte("john")) {
+				t.Errorf("OutboxMessage() payload = %s, want it cleared only once delivered", got.Payload)
+			}
+		})
+	}
+}
@@ -8,39 +8,162 @@ import (
 	"log"
 	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/hako/branca"
 	"github.com/nicolasparada/go-passwordless-demo/notification"
+	"golang.org/x/text/language"
 )
 
 const (
-	verificationCodeTTL = time.Minute * 20
-	authTokenTTL        = time.Hour * 24 * 14
+	verificationCodeTTL    = time.Minute * 20
+	authTokenTTL           = time.Hour * 24 * 14
+	usernameReservationTTL = time.Hour * 24 * 30
 )
 
 var KeyAuthUserID = struct{ name string }{name: "key-auth-user-id"}
 
+// KeyAcceptLanguage holds the raw Accept-Language header of the request.
+// Used to localize notifications to users without a locale.
+var KeyAcceptLanguage = struct{ name string }{name: "key-accept-language"}
+
+// KeyRequestID holds the ID of the request, if any.
+// The service prefixes its log lines with it.
+var KeyRequestID = struct{ name string }{name: "key-request-id"}
+
 var (
 	ErrInvalidEmail             = errors.New("invalid email")
 	ErrInvalidRedirectURI       = errors.New("invalid redirect URI")
 	ErrUntrustedRedirectURI     = errors.New("untrusted redirect URI")
 	ErrInvalidVerificationCode  = errors.New("invalid verification code")
 	ErrInvalidUsername          = errors.New("invalid username")
+	ErrInvalidDisplayName       = errors.New("invalid display name")
+	ErrInvalidAvatarURL         = errors.New("invalid avatar URL")
+	ErrInvalidLocale            = errors.New("invalid locale")
+	ErrInvalidTimeZone          = errors.New("invalid time zone")
+	ErrInvalidPhoneNumber       = errors.New("invalid phone number")
+	ErrSMSUnavailable           = errors.New("sms unavailable")
 	ErrVerificationCodeNotFound = errors.New("verification code not found")
 	ErrVerificationCodeExpired  = errors.New("verification code expired")
 	ErrUserNotFound             = errors.New("user not found")
 	ErrEmailTaken               = errors.New("email taken")
 	ErrUsernameTaken            = errors.New("username taken")
+	ErrPhoneNumberTaken         = errors.New("phone number taken")
+	ErrInvalidPhoneNumberCode   = errors.New("invalid phone number code")
+	ErrPhoneNumberCodeNotFound  = errors.New("phone number code not found")
+	ErrPhoneNumberCodeExpired   = errors.New("phone number code expired")
+	ErrReleasedUsernameNotFound = errors.New("released username not found")
+	ErrInvalidOutboxMessageID   = errors.New("invalid outbox message ID")
+	ErrOutboxMessageNotFound    = errors.New("outbox message not found")
 	ErrUnauthenticated          = errors.New("unauthenticated")
+	ErrInvalidUserStatus        = errors.New("invalid user status")
+	ErrUserSuspended            = errors.New("user suspended")
+	ErrUserBanned               = errors.New("user banned")
+	ErrInvalidSuppressionReason = errors.New("invalid suppression reason")
+	ErrSuppressionNotFound      = errors.New("suppression not found")
+	ErrEmailSuppressed          = errors.New("email suppressed")
+)
+
+// ErrorKind classifies errors so every transport reports them alike.
+type ErrorKind int
+
+const (
+	// ErrorKindInternal is for unexpected errors
+	// whose details should not be exposed.
+	ErrorKindInternal ErrorKind = iota
+	ErrorKindInvalidArgument
+	ErrorKindPermissionDenied
+	ErrorKindNotFound
+	ErrorKindUnauthenticated
+	ErrorKindAlreadyExists
 )
 
+// ErrorCodeInternal is the code of unexpected errors.
+const ErrorCodeInternal = "internal_error"
+
+type errorInfo struct {
+	kind ErrorKind
+	code string
+}
+
+// errorInfos are the errors expected to reach clients.
+// Codes are stable identifiers clients and metrics can match on.
+var errorInfos = map[error]errorInfo{
+	ErrInvalidEmail:             {ErrorKindInvalidArgument, "invalid_email"},
+	ErrInvalidRedirectURI:       {ErrorKindInvalidArgument, "invalid_redirect_uri"},
+	ErrInvalidVerificationCode:  {ErrorKindInvalidArgument, "invalid_verification_code"},
+	ErrInvalidUsername:          {ErrorKindInvalidArgument, "invalid_username"},
+	ErrInvalidDisplayName:       {ErrorKindInvalidArgument, "invalid_display_name"},
+	ErrInvalidAvatarURL:         {ErrorKindInvalidArgument, "invalid_avatar_url"},
+	ErrInvalidLocale:            {ErrorKindInvalidArgument, "invalid_locale"},
+	ErrInvalidTimeZone:          {ErrorKindInvalidArgument, "invalid_time_zone"},
+	ErrInvalidPhoneNumber:       {ErrorKindInvalidArgument, "invalid_phone_number"},
+	ErrSMSUnavailable:           {ErrorKindInvalidArgument, "sms_unavailable"},
+	ErrInvalidPhoneNumberCode:   {ErrorKindInvalidArgument, "invalid_phone_number_code"},
+	ErrInvalidOutboxMessageID:   {ErrorKindInvalidArgument, "invalid_outbox_message_id"},
+	ErrInvalidSuppressionReason: {ErrorKindInvalidArgument, "invalid_suppression_reason"},
+	ErrEmailSuppressed:          {ErrorKindInvalidArgument, "email_suppressed"},
+
+	ErrUntrustedRedirectURI: {ErrorKindPermissionDenied, "untrusted_redirect_uri"},
+	ErrUserSuspended:        {ErrorKindPermissionDenied, "user_suspended"},
+	ErrUserBanned:           {ErrorKindPermissionDenied, "user_banned"},
+
+	ErrVerificationCodeNotFound: {ErrorKindNotFound, "verification_code_not_found"},
+	ErrUserNotFound:             {ErrorKindNotFound, "user_not_found"},
+	ErrOutboxMessageNotFound:    {ErrorKindNotFound, "outbox_message_not_found"},
+	ErrPhoneNumberCodeNotFound:  {ErrorKindNotFound, "phone_number_code_not_found"},
+
+	ErrVerificationCodeExpired: {ErrorKindUnauthenticated, "verification_code_expired"},
+	ErrPhoneNumberCodeExpired:  {ErrorKindUnauthenticated, "phone_number_code_expired"},
+	ErrUnauthenticated:         {ErrorKindUnauthenticated, "unauthenticated"},
+
+	ErrEmailTaken:       {ErrorKindAlreadyExists, "email_taken"},
+	ErrUsernameTaken:    {ErrorKindAlreadyExists, "username_taken"},
+	ErrPhoneNumberTaken: {ErrorKindAlreadyExists, "phone_number_taken"},
+}
+
+// ErrorKindOf returns the kind of err.
+// Errors not declared by this package are internal ones.
+func ErrorKindOf(err error) ErrorKind {
+	return errorInfos[err].kind
+}
+
+// ErrorCode returns the stable code of err,
+// or ErrorCodeInternal for unexpected errors.
+func ErrorCode(err error) string {
+	info, ok := errorInfos[err]
+	if !ok {
+		return ErrorCodeInternal
+	}
+
+	return info.code
+}
+
 type Service struct {
-	Logger          *log.Logger
-	Origin          *url.URL
-	Repository      Repository
-	MagicLinkSender NotificationSender
-	AuthTokenKey    string
+	Logger *log.Logger
+	Origin *url.URL
+	// TrustedOrigins are other origins, like "https://app.example.org",
+	// allowed as redirect URIs and to call the API cross-origin.
+	TrustedOrigins []string
+	Repository     Repository
+	EmailSender    NotificationSender
+	// SMSSender is optional.
+	// Setting and logging in with phone numbers is disabled without it.
+	SMSSender          NotificationSender
+	AuthTokenKey       string
+	EmailNormalization EmailNormalization
+	// LoginAlerts emails users each time they login,
+	// except when signing up.
+	LoginAlerts bool
+	// OutboxMaxAttempts defaults to 8.
+	OutboxMaxAttempts int
+
+	outboxOnce sync.Once
+	outboxWake chan struct{}
 }
 
 type Repository interface {
@@ -52,8 +175,41 @@ type Repository interface {
 
 	UserExistsByEmail(ctx context.Context, email string) (bool, error)
 	UserByEmail(ctx context.Context, email string) (User, error)
+	// UserByPhoneNumber only finds confirmed phone numbers.
+	UserByPhoneNumber(ctx context.Context, phoneNumber string) (User, error)
 	StoreUser(ctx context.Context, email, username string) (User, error)
 	User(ctx context.Context, userID string) (User, error)
+	UpdateUser(ctx context.Context, userID string, params UpdateUserParams) (User, error)
+	UpdateUserLastLogin(ctx context.Context, userID string) (time.Time, error)
+	UpdateUserStatus(ctx context.Context, userID string, status UserStatus, reason string, expiresAt *time.Time) (User, error)
+	UserByUsername(ctx context.Context, username string) (User, error)
+	UpdateUsername(ctx context.Context, userID, username string) (User, error)
+
+	// StorePhoneNumberCode replaces the pending phone number of the user, if any.
+	StorePhoneNumberCode(ctx context.Context, userID, phoneNumber, code string) (PhoneNumberCode, error)
+	PhoneNumberCode(ctx context.Context, userID string) (PhoneNumberCode, error)
+	DeletePhoneNumberCode(ctx context.Context, userID string) error
+
+	StoreReleasedUsername(ctx context.Context, userID, username string) (ReleasedUsername, error)
+	ReleasedUsername(ctx context.Context, username string) (ReleasedUsername, error)
+
+	// StoreOutboxMessage stores a pending message.
+	// traceParent is the W3C traceparent of the request queuing it, if any.
+	StoreOutboxMessage(ctx context.Context, channel OutboxChannel, to string, payload []byte, traceParent string) (OutboxMessage, error)
+	OutboxMessage(ctx context.Context, id string) (OutboxMessage, error)
+	// ClaimOutboxMessages returns up to limit pending messages due for delivery,
+	// leasing them so they are not claimed again until the lease expires.
+	// Each claim counts as a delivery attempt, even if it never finishes.
+	ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error)
+	// MarkOutboxMessageDelivered also clears the payload,
+	// so magic links and codes are not kept once sent.
+	MarkOutboxMessageDelivered(ctx context.Context, id string) error
+	RetryOutboxMessage(ctx context.Context, id, lastErr string, delay time.Duration) error
+	DeadLetterOutboxMessage(ctx context.Context, id, lastErr string) error
+
+	// StoreSuppression adds or updates the suppression of the given email.
+	StoreSuppression(ctx context.Context, email string, reason SuppressionReason, detail string) (Suppression, error)
+	Suppression(ctx context.Context, email string) (Suppression, error)
 }
 
 type VerificationCode struct {
@@ -66,10 +222,6 @@ func (vc VerificationCode) Expired() bool {
 	return vc.CreatedAt.Add(verificationCodeTTL).Before(time.Now())
 }
 
-type NotificationSender interface {
-	Send(ctx context.Context, data notification.MagicLinkData, to string) error
-}
-
 type Auth struct {
 	Token     string    `json:"token"`
 	ExpiresAt time.Time `json:"expiresAt"`
@@ -77,45 +229,173 @@ type Auth struct {
 }
 
 type User struct {
-	ID       string `json:"id"`
-	Email    string `json:"email"`
-	Username string `json:"username"`
+	ID          string     `json:"id"`
+	Email       string     `json:"email"`
+	Username    string     `json:"username"`
+	DisplayName *string    `json:"displayName"`
+	AvatarURL   *string    `json:"avatarURL"`
+	Locale      *string    `json:"locale"`
+	TimeZone    *string    `json:"timeZone"`
+	PhoneNumber *string    `json:"phoneNumber"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+
+	Status          UserStatus `json:"status"`
+	StatusReason    *string    `json:"statusReason"`
+	StatusExpiresAt *time.Time `json:"statusExpiresAt"`
+}
+
+// UserProfile is the public part of a user
+// that other users are allowed to see.
+type UserProfile struct {
+	ID          string  `json:"id"`
+	Username    string  `json:"username"`
+	DisplayName *string `json:"displayName"`
+	AvatarURL   *string `json:"avatarURL"`
 }
 
-func (svc *Service) SendMagicLink(ctx context.Context, email, redirectURI string) error {
-	if !isValidEmail(email) {
-		return ErrInvalidEmail
+// Profile returns the public profile of the user.
+func (u User) Profile() UserProfile {
+	return UserProfile{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarURL,
 	}
+}
+
+// UpdateUserParams holds the user profile fields to update.
+// Nil fields are left untouched and empty strings clear the field.
+// Through Service.UpdateUser, a new phone number is only pending
+// until confirmed with Service.ConfirmPhoneNumber.
+type UpdateUserParams struct {
+	DisplayName *string
+	AvatarURL   *string
+	Locale      *string
+	TimeZone    *string
+	PhoneNumber *string
+}
+
+// SendMagicLink queues a magic link to the given email address.
+// If "to" is a phone number instead, the magic link is sent by SMS
+// to the user with that phone number.
+// The returned outbox message tells the delivery status,
+// except for SMS, whose message has no ID to check it.
+// Otherwise it would tell whether the phone number belongs to a user.
+func (svc *Service) SendMagicLink(ctx context.Context, to, redirectURI string) (OutboxMessage, error) {
+	if isPhoneNumberLike(to) {
+		return svc.sendMagicLinkSMS(ctx, to, redirectURI)
+	}
+
+	var msg OutboxMessage
 
-	_, err := svc.ValidateRedirectURI(redirectURI)
+	email, err := svc.EmailNormalization.normalizeEmail(to)
 	if err != nil {
-		return err
+		return msg, err
 	}
 
-	vc, err := svc.Repository.StoreVerificationCode(ctx, email)
+	_, err = svc.ValidateRedirectURI(redirectURI)
 	if err != nil {
-		return err
+		return msg, err
+	}
+
+	if err := svc.checkSuppression(ctx, email); err != nil {
+		return msg, err
+	}
+
+	u, err := svc.Repository.UserByEmail(ctx, email)
+	if err != nil && err != ErrUserNotFound {
+		return msg, err
+	}
+
+	if err == nil {
+		if err := u.statusErr(); err != nil {
+			return msg, err
+		}
 	}
 
-	// See transport/http/handler.go
-	q := url.Values{}
-	q.Set("email", email)
-	q.Set("code", vc.Code)
-	q.Set("redirect_uri", redirectURI)
-	magicLink := cloneURL(svc.Origin)
-	magicLink.Path = "/api/verify-magic-link"
-	magicLink.RawQuery = q.Encode()
-
-	err = svc.MagicLinkSender.Send(ctx, notification.MagicLinkData{
-		Origin:    svc.Origin,
-		TTL:       verificationCodeTTL,
-		MagicLink: magicLink,
-	}, email)
+	return svc.sendMagicLink(ctx, OutboxChannelEmail, email, email, redirectURI, preferredLanguages(ctx, u))
+}
+
+func (svc *Service) sendMagicLinkSMS(ctx context.Context, phoneNumber, redirectURI string) (OutboxMessage, error) {
+	var msg OutboxMessage
+
+	if svc.SMSSender == nil {
+		return msg, ErrSMSUnavailable
+	}
+
+	phoneNumber, err := normalizePhoneNumber(phoneNumber)
 	if err != nil {
-		return fmt.Errorf("could not send magic link to user: %w", err)
+		return msg, err
 	}
 
-	return nil
+	_, err = svc.ValidateRedirectURI(redirectURI)
+	if err != nil {
+		return msg, err
+	}
+
+	// Phone numbers are a secondary identity.
+	// Only existing users can login with them.
+	u, err := svc.Repository.UserByPhoneNumber(ctx, phoneNumber)
+	if err == ErrUserNotFound {
+		// Respond as if it was sent
+		// so phone numbers of users cannot be told apart.
+		now := time.Now()
+		return anonymousOutboxMessage(OutboxMessage{Channel: OutboxChannelSMS, NextAttemptAt: now, CreatedAt: now}), nil
+	}
+
+	if err != nil {
+		return msg, err
+	}
+
+	if err := u.statusErr(); err != nil {
+		return msg, err
+	}
+
+	msg, err = svc.sendMagicLink(ctx, OutboxChannelSMS, u.Email, phoneNumber, redirectURI, preferredLanguages(ctx, u))
+	if err != nil {
+		return msg, err
+	}
+
+	return anonymousOutboxMessage(msg), nil
+}
+
+// sendMagicLink stores a verification code for the given email
+// along with an outbox message carrying the magic link,
+// then wakes the outbox dispatcher up to deliver it.
+func (svc *Service) sendMagicLink(ctx context.Context, channel OutboxChannel, email, to, redirectURI string, languages []string) (OutboxMessage, error) {
+	var msg OutboxMessage
+
+	err := svc.Repository.ExecuteTx(ctx, func(ctx context.Context) error {
+		vc, err := svc.Repository.StoreVerificationCode(ctx, email)
+		if err != nil {
+			return err
+		}
+
+		// See transport/http/handler.go
+		q := url.Values{}
+		q.Set("email", email)
+		q.Set("code", vc.Code)
+		q.Set("redirect_uri", redirectURI)
+		magicLink := cloneURL(svc.Origin)
+		magicLink.Path = "/api/verify-magic-link"
+		magicLink.RawQuery = q.Encode()
+
+		msg, err = svc.queueNotification(ctx, channel, to, notification.MagicLinkData{
+			Origin:    notification.URL{URL: svc.Origin},
+			TTL:       verificationCodeTTL,
+			MagicLink: notification.URL{URL: magicLink},
+			Languages: languages,
+		})
+		return err
+	})
+	if err != nil {
+		return msg, err
+	}
+
+	svc.wakeOutboxDispatcher()
+
+	return msg, nil
 }
 
 func (svc *Service) ValidateRedirectURI(rawurl string) (*url.URL, error) {
@@ -124,7 +404,9 @@ func (svc *Service) ValidateRedirectURI(rawurl string) (*url.URL, error) {
 		return nil, ErrInvalidRedirectURI
 	}
 
-	if uri.Host != svc.Origin.Host {
+	// Own origin redirect URIs were always trusted by host only.
+	// Cross-origin requests are not, see IsTrustedOrigin.
+	if !strings.EqualFold(uri.Host, svc.Origin.Host) && !svc.isTrustedURL(uri) {
 		return nil, ErrUntrustedRedirectURI
 	}
 
@@ -134,8 +416,9 @@ func (svc *Service) ValidateRedirectURI(rawurl string) (*url.URL, error) {
 func (svc *Service) VerifyMagicLink(ctx context.Context, email, code string, username *string) (Auth, error) {
 	var auth Auth
 
-	if !isValidEmail(email) {
-		return auth, ErrInvalidEmail
+	email, err := svc.EmailNormalization.normalizeEmail(email)
+	if err != nil {
+		return auth, err
 	}
 
 	if !isValidVerificationCode(code) {
@@ -155,6 +438,7 @@ func (svc *Service) VerifyMagicLink(ctx context.Context, email, code string, use
 		return auth, ErrVerificationCodeExpired
 	}
 
+	now := time.Now()
 	err = svc.Repository.ExecuteTx(ctx, func(ctx context.Context) error {
 		exists, err := svc.Repository.UserExistsByEmail(ctx, vc.Email)
 		if err != nil {
@@ -163,47 +447,91 @@ func (svc *Service) VerifyMagicLink(ctx context.Context, email, code string, use
 
 		if exists {
 			auth.User, err = svc.Repository.UserByEmail(ctx, vc.Email)
-			return err
+			if err != nil {
+				return err
+			}
+
+			if err := auth.User.statusErr(); err != nil {
+				return err
+			}
+
+			if !svc.LoginAlerts {
+				return nil
+			}
+
+			return svc.notifyUser(ctx, auth.User, svc.loginAlertData(ctx, auth.User, now))
 		}
 
 		if username == nil {
 			return ErrUserNotFound
 		}
 
+		rel, err := svc.Repository.ReleasedUsername(ctx, *username)
+		if err != nil && err != ErrReleasedUsernameNotFound {
+			return err
+		}
+
+		if err == nil && rel.Reserved() {
+			return ErrUsernameTaken
+		}
+
 		auth.User, err = svc.Repository.StoreUser(ctx, vc.Email, *username)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return svc.notifyUser(ctx, auth.User, svc.welcomeData(ctx, auth.User))
 	})
 	if err != nil {
 		return auth, err
 	}
 
+	svc.wakeOutboxDispatcher()
+
+	lastLoginAt, err := svc.Repository.UpdateUserLastLogin(ctx, auth.User.ID)
+	if err != nil {
+		return auth, err
+	}
+
+	auth.User.LastLoginAt = &lastLoginAt
+
 	auth.ExpiresAt = time.Now().Add(authTokenTTL)
 	auth.Token, err = svc.authTokenCodec().EncodeToString(auth.User.ID)
 	if err != nil {
 		return auth, fmt.Errorf("could not generate auth token: %w", err)
 	}
 
+	logger := svc.logger(ctx)
 	go func() {
 		_, err := svc.Repository.DeleteVerificationCode(context.Background(), email, code)
 		if err != nil {
-			svc.Logger.Printf("failed to delete verification code: %v\n", err)
+			logger.Printf("failed to delete verification code: %v\n", err)
 		}
 	}()
 
 	return auth, nil
 }
 
+// logger returns the service logger
+// prefixing its lines with the request ID, if any.
+func (svc *Service) logger(ctx context.Context) *log.Logger {
+	id, ok := ctx.Value(KeyRequestID).(string)
+	if !ok {
+		return svc.Logger
+	}
+
+	return log.New(svc.Logger.Writer(), fmt.Sprintf("%srequest_id=%s ", svc.Logger.Prefix(), id), svc.Logger.Flags())
+}
+
 func (svc *Service) authTokenCodec() *branca.Branca {
 	cdc := branca.NewBranca(svc.AuthTokenKey)
 	cdc.SetTTL(uint32(authTokenTTL.Seconds()))
 	return cdc
 }
 
-func (svc *Service) ParseAuthToken(token string) (userID string, err error) {
+// ParseAuthToken decodes the given auth token
+// and makes sure its user still exists and is active.
+func (svc *Service) ParseAuthToken(ctx context.Context, token string) (userID string, err error) {
 	userID, err = svc.authTokenCodec().DecodeToString(token)
 	if err == branca.ErrInvalidToken || err == branca.ErrInvalidTokenVersion {
 		return "", ErrUnauthenticated
@@ -213,7 +541,24 @@ func (svc *Service) ParseAuthToken(token string) (userID string, err error) {
 		return "", ErrUnauthenticated
 	}
 
-	return userID, err
+	if err != nil {
+		return "", err
+	}
+
+	u, err := svc.Repository.User(ctx, userID)
+	if err == ErrUserNotFound {
+		return "", ErrUnauthenticated
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.statusErr(); err != nil {
+		return "", err
+	}
+
+	return userID, nil
 }
 
 func (svc *Service) AuthUser(ctx context.Context) (User, error) {
@@ -224,13 +569,102 @@ func (svc *Service) AuthUser(ctx context.Context) (User, error) {
 		return u, ErrUnauthenticated
 	}
 
-	return svc.Repository.User(ctx, authUserID)
+	u, err := svc.Repository.User(ctx, authUserID)
+	if err != nil {
+		return u, err
+	}
+
+	return u, u.statusErr()
 }
 
-var reEmail = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+func (svc *Service) UpdateUser(ctx context.Context, params UpdateUserParams) (User, error) {
+	var u User
+
+	authUserID, ok := ctx.Value(KeyAuthUserID).(string)
+	if !ok {
+		return u, ErrUnauthenticated
+	}
+
+	if params.DisplayName != nil {
+		displayName := strings.TrimSpace(*params.DisplayName)
+		if !isValidDisplayName(displayName) {
+			return u, ErrInvalidDisplayName
+		}
+
+		params.DisplayName = &displayName
+	}
+
+	if params.AvatarURL != nil && !isValidAvatarURL(*params.AvatarURL) {
+		return u, ErrInvalidAvatarURL
+	}
+
+	if params.Locale != nil && *params.Locale != "" {
+		tag, err := language.Parse(*params.Locale)
+		if err != nil {
+			return u, ErrInvalidLocale
+		}
+
+		locale := tag.String()
+		params.Locale = &locale
+	}
+
+	if params.TimeZone != nil && !isValidTimeZone(*params.TimeZone) {
+		return u, ErrInvalidTimeZone
+	}
+
+	// New phone numbers are texted a code to confirm them first.
+	var pendingPhoneNumber string
+	if params.PhoneNumber != nil && *params.PhoneNumber != "" {
+		if svc.SMSSender == nil {
+			return u, ErrSMSUnavailable
+		}
+
+		phoneNumber, err := normalizePhoneNumber(*params.PhoneNumber)
+		if err != nil {
+			return u, err
+		}
+
+		pendingPhoneNumber = phoneNumber
+		params.PhoneNumber = nil
+	}
 
-func isValidEmail(s string) bool {
-	return reEmail.MatchString(s)
+	err := svc.Repository.ExecuteTx(ctx, func(ctx context.Context) error {
+		old, err := svc.Repository.User(ctx, authUserID)
+		if err != nil {
+			return err
+		}
+
+		u, err = svc.Repository.UpdateUser(ctx, authUserID, params)
+		if err != nil {
+			return err
+		}
+
+		if pendingPhoneNumber != "" && !strPtrEqual(u.PhoneNumber, &pendingPhoneNumber) {
+			return svc.requestPhoneNumberCode(ctx, u, pendingPhoneNumber)
+		}
+
+		if params.PhoneNumber == nil {
+			return nil
+		}
+
+		// Clearing the phone number discards the pending one too.
+		if err := svc.Repository.DeletePhoneNumberCode(ctx, authUserID); err != nil {
+			return err
+		}
+
+		if strPtrEqual(old.PhoneNumber, u.PhoneNumber) {
+			return nil
+		}
+
+		return svc.notifyUser(ctx, u, svc.accountChangeData(ctx, u, notification.AccountChangePhoneNumber))
+	})
+	if err != nil {
+		return u, err
+	}
+
+	svc.wakeOutboxDispatcher()
+
+	return u, nil
 }
 
 var reUsername = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,17}$`)
@@ -239,6 +673,51 @@ func isValidUsername(s string) bool {
 	return reUsername.MatchString(s)
 }
 
+const maxDisplayNameLength = 64
+
+func isValidDisplayName(s string) bool {
+	if utf8.RuneCountInString(s) > maxDisplayNameLength {
+		return false
+	}
+
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+const maxAvatarURLLength = 2048
+
+func isValidAvatarURL(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	if len(s) > maxAvatarURLLength {
+		return false
+	}
+
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+func isValidTimeZone(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	// time.LoadLocation also accepts "Local" which depends on the server.
+	if s == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(s)
+	return err == nil
+}
+
 var reUUID4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
 
 func isValidVerificationCode(s string) bool {
@@ -0,0 +1,85 @@
+package passwordless
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorCode(t *testing.T) {
+	seen := map[string]error{}
+	for err, info := range errorInfos {
+		if info.kind == ErrorKindInternal {
+			t.Errorf("%q is declared as internal", err)
+		}
+
+		if prev, ok := seen[info.code]; ok {
+			t.Errorf("%q and %q share the %q code", prev, err, info.code)
+		}
+		seen[info.code] = err
+	}
+
+	unexpected := errors.New("connection reset")
+	if got := ErrorCode(unexpected); got != ErrorCodeInternal {
+		t.Errorf("ErrorCode(unexpected) = %q, want %q", got, ErrorCodeInternal)
+	}
+
+	if got := ErrorKindOf(unexpected); got != ErrorKindInternal {
+		t.Errorf("ErrorKindOf(unexpected) = %v, want %v", got, ErrorKindInternal)
+	}
+}
+
+func TestService_logger(t *testing.T) {
+	var logs bytes.Buffer
+	svc := &Service{Logger: log.New(&logs, "passwordless ", 0)}
+
+	svc.logger(context.Background()).Print("without")
+	ctx := context.WithValue(context.Background(), KeyRequestID, "abc-123")
+	svc.logger(ctx).Print("with")
+
+	want := "passwordless without\npasswordless request_id=abc-123 with\n"
+	if got := logs.String(); got != want {
+		t.Errorf("logs = %q, want %q", got, want)
+	}
+}
+
+func TestService_SendMagicLink_phoneNumber(t *testing.T) {
+	repo := newMemRepo()
+	svc := newTestService(t, repo)
+
+	u := storeTestUser(t, repo, "john")
+	known := "+15550000001"
+	if _, err := repo.UpdateUser(context.Background(), u.ID, UpdateUserParams{PhoneNumber: &known}); err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+
+	send := func(phoneNumber string) OutboxMessage {
+		t.Helper()
+		msg, err := svc.SendMagicLink(context.Background(), phoneNumber, "https://example.org/callback")
+		if err != nil {
+			t.Fatalf("SendMagicLink(%q) error = %v", phoneNumber, err)
+		}
+
+		msg.NextAttemptAt = time.Time{}
+		msg.CreatedAt = time.Time{}
+		return msg
+	}
+
+	gotKnown, gotUnknown := send(known), send("+15550000002")
+	if !reflect.DeepEqual(gotKnown, gotUnknown) {
+		t.Errorf("SendMagicLink() = %+v for a known phone number, %+v for an unknown one, want them equal", gotKnown, gotUnknown)
+	}
+
+	if gotKnown.ID != "" {
+		t.Errorf("SendMagicLink() ID = %q, want none", gotKnown.ID)
+	}
+
+	msgs := repo.outboxMessages()
+	if len(msgs) != 1 || msgs[0].To != known {
+		t.Errorf("outbox messages = %+v, want one to %s", msgs, known)
+	}
+}
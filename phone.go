@@ -0,0 +1,147 @@
+package passwordless
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+const phoneNumberCodeTTL = time.Minute * 10
+
+var rePhoneNumber = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// rePhoneNumberCode matches the codes of newPhoneNumberCode.
+var rePhoneNumberCode = regexp.MustCompile(`^[0-9]{6}$`)
+
+// phoneNumberReplacer removes the usual separators people type in phone numbers.
+var phoneNumberReplacer = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+// PhoneNumberCode is texted to a phone number
+// to confirm the user owns it before setting it as theirs.
+// Users have at most one pending phone number.
+type PhoneNumberCode struct {
+	UserID      string
+	PhoneNumber string
+	Code        string
+	CreatedAt   time.Time
+}
+
+func (pc PhoneNumberCode) Expired() bool {
+	return pc.CreatedAt.Add(phoneNumberCodeTTL).Before(time.Now())
+}
+
+// ConfirmPhoneNumber sets the pending phone number of the authenticated user
+// given the code texted to it. Only then it can be used to login.
+// A wrong code discards the pending phone number
+// so codes cannot be guessed. Update the phone number again to get a new one.
+func (svc *Service) ConfirmPhoneNumber(ctx context.Context, code string) (User, error) {
+	var u User
+
+	authUserID, ok := ctx.Value(KeyAuthUserID).(string)
+	if !ok {
+		return u, ErrUnauthenticated
+	}
+
+	if !rePhoneNumberCode.MatchString(code) {
+		return u, ErrInvalidPhoneNumberCode
+	}
+
+	pc, err := svc.Repository.PhoneNumberCode(ctx, authUserID)
+	if err != nil {
+		return u, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(pc.Code), []byte(code)) != 1 {
+		if err := svc.Repository.DeletePhoneNumberCode(ctx, authUserID); err != nil {
+			return u, err
+		}
+
+		return u, ErrPhoneNumberCodeNotFound
+	}
+
+	if pc.Expired() {
+		if err := svc.Repository.DeletePhoneNumberCode(ctx, authUserID); err != nil {
+			return u, err
+		}
+
+		return u, ErrPhoneNumberCodeExpired
+	}
+
+	err = svc.Repository.ExecuteTx(ctx, func(ctx context.Context) error {
+		u, err = svc.Repository.UpdateUser(ctx, authUserID, UpdateUserParams{PhoneNumber: &pc.PhoneNumber})
+		if err != nil {
+			return err
+		}
+
+		if err := svc.Repository.DeletePhoneNumberCode(ctx, authUserID); err != nil {
+			return err
+		}
+
+		return svc.notifyUser(ctx, u, svc.accountChangeData(ctx, u, notification.AccountChangePhoneNumber))
+	})
+	if err != nil {
+		return u, err
+	}
+
+	svc.wakeOutboxDispatcher()
+
+	return u, nil
+}
+
+// requestPhoneNumberCode stores phoneNumber as the pending one of the user
+// and queues an SMS to it with the code to confirm it.
+// Taken phone numbers are only rejected once confirmed,
+// so they cannot be told apart from free ones without owning them.
+// Call it inside a transaction.
+func (svc *Service) requestPhoneNumberCode(ctx context.Context, u User, phoneNumber string) error {
+	code, err := newPhoneNumberCode()
+	if err != nil {
+		return err
+	}
+
+	pc, err := svc.Repository.StorePhoneNumberCode(ctx, u.ID, phoneNumber, code)
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.queueNotification(ctx, OutboxChannelSMS, pc.PhoneNumber, notification.PhoneNumberCodeData{
+		Origin:    notification.URL{URL: svc.Origin},
+		Code:      pc.Code,
+		TTL:       phoneNumberCodeTTL,
+		Languages: preferredLanguages(ctx, u),
+	})
+	return err
+}
+
+func newPhoneNumberCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("could not generate phone number code: %w", err)
+	}
+
+	return fmt.Sprintf("%06d", n), nil
+}
+
+// isPhoneNumberLike tells whether s was meant to be a phone number
+// rather than an email address.
+func isPhoneNumberLike(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "+")
+}
+
+// normalizePhoneNumber validates the given phone number
+// and returns it in E.164 format.
+func normalizePhoneNumber(s string) (string, error) {
+	s = phoneNumberReplacer.Replace(strings.TrimSpace(s))
+	if !rePhoneNumber.MatchString(s) {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	return s, nil
+}
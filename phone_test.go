@@ -0,0 +1,86 @@
+package passwordless
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_newPhoneNumberCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := newPhoneNumberCode()
+		if err != nil {
+			t.Fatalf("newPhoneNumberCode() error = %v", err)
+		}
+
+		if !rePhoneNumberCode.MatchString(code) {
+			t.Fatalf("newPhoneNumberCode() = %q, want 6 digits", code)
+		}
+
+		seen[code] = true
+	}
+
+	if len(seen) < 90 {
+		t.Errorf("newPhoneNumberCode() returned %d distinct codes out of 100", len(seen))
+	}
+}
+
+func TestService_ConfirmPhoneNumber(t *testing.T) {
+	const phoneNumber = "+15550000001"
+
+	tests := []struct {
+		name    string
+		taken   bool
+		expired bool
+		wantErr error
+	}{
+		{name: "free"},
+		{name: "taken", taken: true, wantErr: ErrPhoneNumberTaken},
+		{name: "expired", expired: true, wantErr: ErrPhoneNumberCodeExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepo()
+			svc := newTestService(t, repo)
+
+			if tt.taken {
+				other := storeTestUser(t, repo, "jane")
+				if _, err := repo.UpdateUser(context.Background(), other.ID, UpdateUserParams{PhoneNumber: strPtr(phoneNumber)}); err != nil {
+					t.Fatalf("UpdateUser() error = %v", err)
+				}
+			}
+
+			u := storeTestUser(t, repo, "john")
+			ctx := authContext(u)
+
+			// Taken phone numbers are texted a code too.
+			if _, err := svc.UpdateUser(ctx, UpdateUserParams{PhoneNumber: strPtr(phoneNumber)}); err != nil {
+				t.Fatalf("UpdateUser() error = %v", err)
+			}
+
+			pc, err := repo.PhoneNumberCode(ctx, u.ID)
+			if err != nil {
+				t.Fatalf("PhoneNumberCode() error = %v", err)
+			}
+
+			if tt.expired {
+				pc.CreatedAt = pc.CreatedAt.Add(-phoneNumberCodeTTL - time.Second)
+				repo.phoneNumberCodes[u.ID] = pc
+			}
+
+			got, err := svc.ConfirmPhoneNumber(ctx, pc.Code)
+			if err != tt.wantErr {
+				t.Fatalf("ConfirmPhoneNumber() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && (got.PhoneNumber == nil || *got.PhoneNumber != phoneNumber) {
+				t.Errorf("ConfirmPhoneNumber() phone number = %v, want %s", got.PhoneNumber, phoneNumber)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
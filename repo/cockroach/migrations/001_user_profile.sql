@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url VARCHAR;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone VARCHAR;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS released_usernames (
+    id UUID NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+    username VARCHAR NOT NULL,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    released_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS released_usernames_username_idx ON released_usernames (username, released_at DESC);
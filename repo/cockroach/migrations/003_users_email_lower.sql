@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (lower(email));
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason VARCHAR;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_expires_at TIMESTAMP;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id UUID NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+    channel VARCHAR NOT NULL,
+    recipient VARCHAR NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS outbox_messages_pending_idx ON outbox_messages (status, next_attempt_at);
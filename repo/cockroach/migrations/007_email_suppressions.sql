@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    email VARCHAR NOT NULL PRIMARY KEY,
+    reason VARCHAR NOT NULL,
+    detail VARCHAR,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
@@ -0,0 +1 @@
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS trace_parent VARCHAR;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS phone_number_codes (
+    user_id UUID NOT NULL PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    phone_number VARCHAR NOT NULL,
+    code VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
@@ -25,4 +25,4 @@ if "$pgcrypto"; then
     cockroach sql --insecure -e "CREATE EXTENSION IF NOT EXISTS \"pgcrypto\""
 fi
 
-cat $(dirname $0)/000_schema.sql | cockroach sql --insecure -d passwordless
+cat $(dirname $0)/*.sql | cockroach sql --insecure -d passwordless
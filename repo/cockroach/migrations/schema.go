@@ -1,8 +1,88 @@
 package migrations
 
 import (
-	_ "embed"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
 )
 
-//go:embed 000_schema.sql
-var Schema string
+//go:embed *.sql
+var files embed.FS
+
+// checks run right before the migration file with the same name.
+var checks = map[string]func(ctx context.Context, db *sql.DB) error{
+	"003_users_email_lower.sql": checkEmailCollisions,
+}
+
+// Migrate executes every sql file in this directory in lexical order.
+// Each file must be idempotent since all of them run every time.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return fmt.Errorf("could not list migration files: %w", err)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if check, ok := checks[name]; ok {
+			err := check(ctx, db)
+			if err != nil {
+				return fmt.Errorf("could not check migration file %s: %w", name, err)
+			}
+		}
+
+		b, err := files.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("could not read migration file %s: %w", name, err)
+		}
+
+		_, err = db.ExecContext(ctx, string(b))
+		if err != nil {
+			return fmt.Errorf("could not execute migration file %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkEmailCollisions reports users whose emails only differ in case,
+// since they would violate users_email_lower_key.
+// Those have to be merged or renamed manually.
+func checkEmailCollisions(ctx context.Context, db *sql.DB) error {
+	query := `
+		SELECT lower(email), string_agg(email, ', ') FROM users
+		GROUP BY lower(email)
+		HAVING count(*) > 1`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("could not sql query select email collisions: %w", err)
+	}
+
+	defer rows.Close()
+
+	var collisions []string
+	for rows.Next() {
+		var email, emails string
+		if err := rows.Scan(&email, &emails); err != nil {
+			return fmt.Errorf("could not sql scan email collision: %w", err)
+		}
+
+		collisions = append(collisions, fmt.Sprintf("%s (%s)", email, emails))
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not sql iterate over email collisions: %w", err)
+	}
+
+	if len(collisions) != 0 {
+		return fmt.Errorf("found %d emails used by more than one user when ignoring case: %s",
+			len(collisions), strings.Join(collisions, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+)
+
+func (repo *Repository) StoreOutboxMessage(ctx context.Context, channel passwordless.OutboxChannel, to string, payload []byte, traceParent string) (passwordless.OutboxMessage, error) {
+	var msg passwordless.OutboxMessage
+	query := `
+		INSERT INTO outbox_messages (channel, recipient, payload, trace_parent)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING ` + outboxMessageColumns
+	row := repo.ext(ctx).QueryRowContext(ctx, query, string(channel), to, string(payload), traceParent)
+	err := scanOutboxMessage(row, &msg)
+	if err != nil {
+		return msg, fmt.Errorf("could not sql insert or scan outbox message: %w", err)
+	}
+
+	return msg, nil
+}
+
+func (repo *Repository) OutboxMessage(ctx context.Context, id string) (passwordless.OutboxMessage, error) {
+	var msg passwordless.OutboxMessage
+	query := "SELECT " + outboxMessageColumns + " FROM outbox_messages WHERE id = $1"
+	row := repo.ext(ctx).QueryRowContext(ctx, query, id)
+	err := scanOutboxMessage(row, &msg)
+	if err == sql.ErrNoRows {
+		return msg, passwordless.ErrOutboxMessageNotFound
+	}
+
+	if err != nil {
+		return msg, fmt.Errorf("could not sql query select or scan outbox message: %w", err)
+	}
+
+	return msg, nil
+}
+
+func (repo *Repository) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]passwordless.OutboxMessage, error) {
+	// The outer WHERE is repeated so concurrent claims
+	// skip rows that got leased in the meantime.
+	query := `
+		UPDATE outbox_messages
+		SET attempts = attempts + 1, next_attempt_at = now() + $2::INT * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM outbox_messages
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+		) AND status = 'pending' AND next_attempt_at <= now()
+		RETURNING ` + outboxMessageColumns
+	rows, err := repo.ext(ctx).QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("could not sql update outbox messages lease: %w", err)
+	}
+
+	defer rows.Close()
+
+	var msgs []passwordless.OutboxMessage
+	for rows.Next() {
+		var msg passwordless.OutboxMessage
+		err := scanOutboxMessage(rows, &msg)
+		if err != nil {
+			return nil, fmt.Errorf("could not sql scan claimed outbox message: %w", err)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not sql iterate over claimed outbox messages: %w", err)
+	}
+
+	return msgs, nil
+}
+
+func (repo *Repository) MarkOutboxMessageDelivered(ctx context.Context, id string) error {
+	query := `
+		UPDATE outbox_messages
+		SET status = 'delivered', delivered_at = now(), payload = 'null'
+		WHERE id = $1`
+	return repo.updateOutboxMessage(ctx, query, id)
+}
+
+func (repo *Repository) RetryOutboxMessage(ctx context.Context, id, lastErr string, delay time.Duration) error {
+	query := `
+		UPDATE outbox_messages
+		SET last_error = $2, next_attempt_at = now() + $3::INT * INTERVAL '1 millisecond'
+		WHERE id = $1`
+	return repo.updateOutboxMessage(ctx, query, id, lastErr, delay.Milliseconds())
+}
+
+func (repo *Repository) DeadLetterOutboxMessage(ctx context.Context, id, lastErr string) error {
+	query := `
+		UPDATE outbox_messages
+		SET status = 'dead', last_error = $2
+		WHERE id = $1`
+	return repo.updateOutboxMessage(ctx, query, id, lastErr)
+}
+
+func (repo *Repository) updateOutboxMessage(ctx context.Context, query string, args ...interface{}) error {
+	result, err := repo.ext(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("could not sql update outbox message: %w", err)
+	}
+
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not sql count updated outbox message rows: %w", err)
+	}
+
+	if ra == 0 {
+		return passwordless.ErrOutboxMessageNotFound
+	}
+
+	return nil
+}
+
+const outboxMessageColumns = `id, channel, recipient, payload, status, attempts, last_error,
+	next_attempt_at, created_at, delivered_at, trace_parent`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOutboxMessage(row scanner, msg *passwordless.OutboxMessage) error {
+	var payload []byte
+	err := row.Scan(
+		&msg.ID,
+		&msg.Channel,
+		&msg.To,
+		&payload,
+		&msg.Status,
+		&msg.Attempts,
+		&msg.LastError,
+		&msg.NextAttemptAt,
+		&msg.CreatedAt,
+		&msg.DeliveredAt,
+		&msg.TraceParent,
+	)
+	msg.Payload = payload
+	return err
+}
@@ -0,0 +1,61 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+)
+
+func (repo *Repository) StorePhoneNumberCode(ctx context.Context, userID, phoneNumber, code string) (passwordless.PhoneNumberCode, error) {
+	var pc passwordless.PhoneNumberCode
+
+	query := `
+		INSERT INTO phone_number_codes (user_id, phone_number, code) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			phone_number = excluded.phone_number,
+			code = excluded.code,
+			created_at = now()
+		RETURNING created_at`
+	row := repo.ext(ctx).QueryRowContext(ctx, query, userID, phoneNumber, code)
+	err := row.Scan(&pc.CreatedAt)
+	if err != nil {
+		return pc, fmt.Errorf("could not sql upsert or scan phone number code: %w", err)
+	}
+
+	pc.UserID = userID
+	pc.PhoneNumber = phoneNumber
+	pc.Code = code
+
+	return pc, nil
+}
+
+func (repo *Repository) PhoneNumberCode(ctx context.Context, userID string) (passwordless.PhoneNumberCode, error) {
+	var pc passwordless.PhoneNumberCode
+
+	query := "SELECT phone_number, code, created_at FROM phone_number_codes WHERE user_id = $1"
+	row := repo.ext(ctx).QueryRowContext(ctx, query, userID)
+	err := row.Scan(&pc.PhoneNumber, &pc.Code, &pc.CreatedAt)
+	if err == sql.ErrNoRows {
+		return pc, passwordless.ErrPhoneNumberCodeNotFound
+	}
+
+	if err != nil {
+		return pc, fmt.Errorf("could not sql query select or scan phone number code: %w", err)
+	}
+
+	pc.UserID = userID
+
+	return pc, nil
+}
+
+func (repo *Repository) DeletePhoneNumberCode(ctx context.Context, userID string) error {
+	query := "DELETE FROM phone_number_codes WHERE user_id = $1"
+	_, err := repo.ext(ctx).ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("could not sql delete phone number code: %w", err)
+	}
+
+	return nil
+}
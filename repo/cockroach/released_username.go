@@ -0,0 +1,48 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+)
+
+func (repo *Repository) StoreReleasedUsername(ctx context.Context, userID, username string) (passwordless.ReleasedUsername, error) {
+	var rel passwordless.ReleasedUsername
+
+	query := "INSERT INTO released_usernames (username, user_id) VALUES ($1, $2) RETURNING released_at"
+	row := repo.ext(ctx).QueryRowContext(ctx, query, username, userID)
+	err := row.Scan(&rel.ReleasedAt)
+	if err != nil {
+		return rel, fmt.Errorf("could not sql insert or scan released username: %w", err)
+	}
+
+	rel.Username = username
+	rel.UserID = userID
+
+	return rel, nil
+}
+
+func (repo *Repository) ReleasedUsername(ctx context.Context, username string) (passwordless.ReleasedUsername, error) {
+	var rel passwordless.ReleasedUsername
+
+	query := `
+		SELECT user_id, released_at FROM released_usernames
+		WHERE username = $1
+		ORDER BY released_at DESC
+		LIMIT 1`
+	row := repo.ext(ctx).QueryRowContext(ctx, query, username)
+	err := row.Scan(&rel.UserID, &rel.ReleasedAt)
+	if err == sql.ErrNoRows {
+		return rel, passwordless.ErrReleasedUsernameNotFound
+	}
+
+	if err != nil {
+		return rel, fmt.Errorf("could not sql query select or scan released username: %w", err)
+	}
+
+	rel.Username = username
+
+	return rel, nil
+}
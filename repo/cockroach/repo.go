@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"github.com/cockroachdb/cockroach-go/crdb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var keyTx = struct{ name string }{name: "key-tx"}
@@ -24,13 +26,21 @@ type ext interface {
 func (repo *Repository) ext(ctx context.Context) ext {
 	tx, ok := ctx.Value(keyTx).(*sql.Tx)
 	if !ok {
-		return repo.DB
+		return tracedExt{ext: repo.DB, system: repo.dbSystem()}
 	}
 
-	return tx
+	return tracedExt{ext: tx, system: repo.dbSystem()}
 }
 
-func (repo *Repository) ExecuteTx(ctx context.Context, txFunc func(ctx context.Context) error) error {
+// ExecuteTx runs txFunc in a transaction,
+// retrying it on serialization errors unless DisableCRDBRetries is set.
+// Each attempt gets recorded as an event of the transaction span.
+func (repo *Repository) ExecuteTx(ctx context.Context, txFunc func(ctx context.Context) error) (err error) {
+	ctx, span := tracer.Start(ctx, "ExecuteTx", trace.WithAttributes(repo.dbSystem()))
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	if repo.DisableCRDBRetries {
 		tx, err := repo.DB.BeginTx(ctx, nil)
 		if err != nil {
@@ -54,7 +64,14 @@ func (repo *Repository) ExecuteTx(ctx context.Context, txFunc func(ctx context.C
 		return nil
 	}
 
+	var attempts int
+	defer func() {
+		span.SetAttributes(attribute.Int("db.transaction.attempts", attempts))
+	}()
+
 	return crdb.ExecuteTx(ctx, repo.DB, nil, func(tx *sql.Tx) error {
+		attempts++
+		span.AddEvent("attempt", trace.WithAttributes(attribute.Int("attempt", attempts)))
 		return txFunc(context.WithValue(ctx, keyTx, tx))
 	})
 }
@@ -0,0 +1,52 @@
+package cockroach_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"testing"
+
+	_ "github.com/lib/pq"
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/repo/cockroach"
+	"github.com/nicolasparada/go-passwordless-demo/repo/cockroach/migrations"
+	"github.com/nicolasparada/go-passwordless-demo/repo/repotest"
+)
+
+// TestRepository runs the conformance suite against the database at
+// TEST_DATABASE_URL. Set TEST_USE_POSTGRES=true when it points to postgres.
+func TestRepository(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("TEST_DATABASE_URL")
+	if !ok {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	usePostgres, _ := strconv.ParseBool(os.Getenv("TEST_USE_POSTGRES"))
+
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("could not open db: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	ctx := context.Background()
+	if usePostgres {
+		_, err := db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS "pgcrypto"`)
+		if err != nil {
+			t.Fatalf("could not create pgcrypto extension: %v", err)
+		}
+	}
+
+	err = migrations.Migrate(ctx, db)
+	if err != nil {
+		t.Fatalf("could not migrate sql schema: %v", err)
+	}
+
+	repotest.Run(t, func(t *testing.T) passwordless.Repository {
+		return &cockroach.Repository{DB: db, DisableCRDBRetries: usePostgres}
+	})
+}
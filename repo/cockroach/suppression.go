@@ -0,0 +1,53 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+)
+
+// StoreSuppression stores the email lowercased since suppressions
+// come from third parties that may change its case.
+func (repo *Repository) StoreSuppression(ctx context.Context, email string, reason passwordless.SuppressionReason, detail string) (passwordless.Suppression, error) {
+	var s passwordless.Suppression
+
+	query := `
+		INSERT INTO email_suppressions (email, reason, detail) VALUES (lower($1), $2, NULLIF($3, ''))
+		ON CONFLICT (email) DO UPDATE SET
+			reason = excluded.reason,
+			detail = excluded.detail,
+			updated_at = now()
+		RETURNING email, reason, detail, created_at, updated_at`
+	row := repo.ext(ctx).QueryRowContext(ctx, query, email, reason, detail)
+	err := scanSuppression(row, &s)
+	if err != nil {
+		return s, fmt.Errorf("could not sql upsert or scan suppression: %w", err)
+	}
+
+	return s, nil
+}
+
+func (repo *Repository) Suppression(ctx context.Context, email string) (passwordless.Suppression, error) {
+	var s passwordless.Suppression
+
+	query := `
+		SELECT email, reason, detail, created_at, updated_at FROM email_suppressions
+		WHERE email = lower($1)`
+	row := repo.ext(ctx).QueryRowContext(ctx, query, email)
+	err := scanSuppression(row, &s)
+	if err == sql.ErrNoRows {
+		return s, passwordless.ErrSuppressionNotFound
+	}
+
+	if err != nil {
+		return s, fmt.Errorf("could not sql query select or scan suppression: %w", err)
+	}
+
+	return s, nil
+}
+
+func scanSuppression(row *sql.Row, s *passwordless.Suppression) error {
+	return row.Scan(&s.Email, &s.Reason, &s.Detail, &s.CreatedAt, &s.UpdatedAt)
+}
@@ -0,0 +1,81 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/nicolasparada/go-passwordless-demo/repo/cockroach"
+
+var tracer = otel.Tracer(instrumentationName)
+
+// tracedExt starts a span for each query.
+// Spans only cover until the query returns,
+// not the time spent scanning its rows.
+type tracedExt struct {
+	ext
+	system attribute.KeyValue
+}
+
+func (e tracedExt) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	op := operation(query)
+	return tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		e.system,
+		semconv.DBOperationKey.String(op),
+		semconv.DBStatementKey.String(query),
+	))
+}
+
+func (e tracedExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := e.start(ctx, query)
+	result, err := e.ext.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (e tracedExt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := e.start(ctx, query)
+	rows, err := e.ext.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (e tracedExt) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := e.start(ctx, query)
+	row := e.ext.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+	return row
+}
+
+func (repo *Repository) dbSystem() attribute.KeyValue {
+	if repo.DisableCRDBRetries {
+		return semconv.DBSystemPostgreSQL
+	}
+
+	return semconv.DBSystemCockroachdb
+}
+
+// operation is the first keyword of query, like SELECT or INSERT.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+
+	return strings.ToUpper(fields[0])
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	passwordless "github.com/nicolasparada/go-passwordless-demo"
@@ -13,7 +14,7 @@ import (
 func (repo *Repository) UserExistsByEmail(ctx context.Context, email string) (bool, error) {
 	var exists bool
 
-	query := "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)"
+	query := "SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1))"
 	row := repo.ext(ctx).QueryRowContext(ctx, query, email)
 	err := row.Scan(&exists)
 	if err != nil {
@@ -25,9 +26,9 @@ func (repo *Repository) UserExistsByEmail(ctx context.Context, email string) (bo
 
 func (repo *Repository) UserByEmail(ctx context.Context, email string) (passwordless.User, error) {
 	var u passwordless.User
-	query := "SELECT id, username FROM users WHERE email = $1"
+	query := "SELECT " + userColumns + " FROM users WHERE lower(email) = lower($1)"
 	row := repo.ext(ctx).QueryRowContext(ctx, query, email)
-	err := row.Scan(&u.ID, &u.Username)
+	err := scanUser(row, &u)
 	if err == sql.ErrNoRows {
 		return u, passwordless.ErrUserNotFound
 	}
@@ -36,16 +37,30 @@ func (repo *Repository) UserByEmail(ctx context.Context, email string) (password
 		return u, fmt.Errorf("could not sql query select or scan user by email: %w", err)
 	}
 
-	u.Email = email
+	return u, nil
+}
+
+func (repo *Repository) UserByPhoneNumber(ctx context.Context, phoneNumber string) (passwordless.User, error) {
+	var u passwordless.User
+	query := "SELECT " + userColumns + " FROM users WHERE phone_number = $1"
+	row := repo.ext(ctx).QueryRowContext(ctx, query, phoneNumber)
+	err := scanUser(row, &u)
+	if err == sql.ErrNoRows {
+		return u, passwordless.ErrUserNotFound
+	}
+
+	if err != nil {
+		return u, fmt.Errorf("could not sql query select or scan user by phone number: %w", err)
+	}
 
 	return u, nil
 }
 
 func (repo *Repository) StoreUser(ctx context.Context, email, username string) (passwordless.User, error) {
 	var u passwordless.User
-	query := "INSERT INTO users (email, username) VALUES ($1, $2) RETURNING id"
+	query := "INSERT INTO users (email, username) VALUES ($1, $2) RETURNING " + userColumns
 	row := repo.ext(ctx).QueryRowContext(ctx, query, email, username)
-	err := row.Scan(&u.ID)
+	err := scanUser(row, &u)
 	if isUniqueViolationError(err) {
 		if strings.Contains(err.Error(), "email") {
 			return u, passwordless.ErrEmailTaken
@@ -59,17 +74,14 @@ func (repo *Repository) StoreUser(ctx context.Context, email, username string) (
 		return u, fmt.Errorf("could not sql insert or scan user: %w", err)
 	}
 
-	u.Email = email
-	u.Username = username
-
 	return u, nil
 }
 
 func (repo *Repository) User(ctx context.Context, userID string) (passwordless.User, error) {
 	var u passwordless.User
-	query := "SELECT email, username FROM users WHERE id = $1"
+	query := "SELECT " + userColumns + " FROM users WHERE id = $1"
 	row := repo.ext(ctx).QueryRowContext(ctx, query, userID)
-	err := row.Scan(&u.Email, &u.Username)
+	err := scanUser(row, &u)
 	if err == sql.ErrNoRows {
 		return u, passwordless.ErrUserNotFound
 	}
@@ -78,11 +90,148 @@ func (repo *Repository) User(ctx context.Context, userID string) (passwordless.U
 		return u, fmt.Errorf("could not sql query select or scan user: %w", err)
 	}
 
-	u.ID = userID
+	return u, nil
+}
+
+func (repo *Repository) UpdateUser(ctx context.Context, userID string, params passwordless.UpdateUserParams) (passwordless.User, error) {
+	var (
+		sets []string
+		args []interface{}
+	)
+	set := func(column string, v *string) {
+		if v == nil {
+			return
+		}
+
+		args = append(args, nullString(*v))
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	set("display_name", params.DisplayName)
+	set("avatar_url", params.AvatarURL)
+	set("locale", params.Locale)
+	set("time_zone", params.TimeZone)
+	set("phone_number", params.PhoneNumber)
+
+	if len(sets) == 0 {
+		return repo.User(ctx, userID)
+	}
+
+	var u passwordless.User
+	args = append(args, userID)
+	query := fmt.Sprintf("UPDATE users SET %s WHERE id = $%d RETURNING %s", strings.Join(sets, ", "), len(args), userColumns)
+	row := repo.ext(ctx).QueryRowContext(ctx, query, args...)
+	err := scanUser(row, &u)
+	if err == sql.ErrNoRows {
+		return u, passwordless.ErrUserNotFound
+	}
+
+	if isUniqueViolationError(err) && strings.Contains(err.Error(), "phone_number") {
+		return u, passwordless.ErrPhoneNumberTaken
+	}
+
+	if err != nil {
+		return u, fmt.Errorf("could not sql update or scan user: %w", err)
+	}
 
 	return u, nil
 }
 
+func (repo *Repository) UpdateUserLastLogin(ctx context.Context, userID string) (time.Time, error) {
+	var lastLoginAt time.Time
+	query := "UPDATE users SET last_login_at = now() WHERE id = $1 RETURNING last_login_at"
+	row := repo.ext(ctx).QueryRowContext(ctx, query, userID)
+	err := row.Scan(&lastLoginAt)
+	if err == sql.ErrNoRows {
+		return lastLoginAt, passwordless.ErrUserNotFound
+	}
+
+	if err != nil {
+		return lastLoginAt, fmt.Errorf("could not sql update or scan user last login: %w", err)
+	}
+
+	return lastLoginAt, nil
+}
+
+func (repo *Repository) UserByUsername(ctx context.Context, username string) (passwordless.User, error) {
+	var u passwordless.User
+	query := "SELECT " + userColumns + " FROM users WHERE username = $1"
+	row := repo.ext(ctx).QueryRowContext(ctx, query, username)
+	err := scanUser(row, &u)
+	if err == sql.ErrNoRows {
+		return u, passwordless.ErrUserNotFound
+	}
+
+	if err != nil {
+		return u, fmt.Errorf("could not sql query select or scan user by username: %w", err)
+	}
+
+	return u, nil
+}
+
+func (repo *Repository) UpdateUsername(ctx context.Context, userID, username string) (passwordless.User, error) {
+	var u passwordless.User
+	query := "UPDATE users SET username = $1 WHERE id = $2 RETURNING " + userColumns
+	row := repo.ext(ctx).QueryRowContext(ctx, query, username, userID)
+	err := scanUser(row, &u)
+	if err == sql.ErrNoRows {
+		return u, passwordless.ErrUserNotFound
+	}
+
+	if isUniqueViolationError(err) {
+		return u, passwordless.ErrUsernameTaken
+	}
+
+	if err != nil {
+		return u, fmt.Errorf("could not sql update or scan username: %w", err)
+	}
+
+	return u, nil
+}
+
+func (repo *Repository) UpdateUserStatus(ctx context.Context, userID string, status passwordless.UserStatus, reason string, expiresAt *time.Time) (passwordless.User, error) {
+	var u passwordless.User
+	query := `
+		UPDATE users SET status = $1, status_reason = $2, status_expires_at = $3
+		WHERE id = $4
+		RETURNING ` + userColumns
+	row := repo.ext(ctx).QueryRowContext(ctx, query, string(status), nullString(reason), expiresAt, userID)
+	err := scanUser(row, &u)
+	if err == sql.ErrNoRows {
+		return u, passwordless.ErrUserNotFound
+	}
+
+	if err != nil {
+		return u, fmt.Errorf("could not sql update or scan user status: %w", err)
+	}
+
+	return u, nil
+}
+
+const userColumns = `id, email, username, display_name, avatar_url, locale, time_zone, phone_number,
+	created_at, last_login_at, status, status_reason, status_expires_at`
+
+func scanUser(row *sql.Row, u *passwordless.User) error {
+	return row.Scan(
+		&u.ID,
+		&u.Email,
+		&u.Username,
+		&u.DisplayName,
+		&u.AvatarURL,
+		&u.Locale,
+		&u.TimeZone,
+		&u.PhoneNumber,
+		&u.CreatedAt,
+		&u.LastLoginAt,
+		&u.Status,
+		&u.StatusReason,
+		&u.StatusExpiresAt,
+	)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
 func isUniqueViolationError(err error) bool {
 	e, ok := err.(*pq.Error)
 	return ok && e.Code == "23505"
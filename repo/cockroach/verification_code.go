@@ -18,6 +18,8 @@ func (repo *Repository) StoreVerificationCode(ctx context.Context, email string)
 		return vc, fmt.Errorf("could not sql insert or scan verification code: %w", err)
 	}
 
+	vc.Email = email
+
 	return vc, nil
 }
 
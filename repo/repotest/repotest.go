@@ -0,0 +1,1004 @@
+// Package repotest provides a conformance test suite
+// for passwordless.Repository implementations.
+package repotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+)
+
+// missingCode is a valid UUIDv4 that is never generated by a repository.
+const missingCode = "00000000-0000-4000-8000-000000000000"
+
+// missingUserID is a valid UUIDv4 that no user has.
+const missingUserID = "00000000-0000-4000-8000-000000000001"
+
+// missingOutboxMessageID is a valid UUIDv4 that no outbox message has.
+const missingOutboxMessageID = "00000000-0000-4000-8000-000000000002"
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+var errRollback = errors.New("rollback")
+
+// Run exercises every passwordless.Repository method against the repositories
+// returned by newRepo. newRepo is called once per subtest.
+// Emails are compared case-insensitively.
+// Data created by the suite uses random emails and usernames,
+// so repositories may share the same underlying storage.
+func Run(t *testing.T, newRepo func(t *testing.T) passwordless.Repository) {
+	t.Run("StoreVerificationCode", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email := randEmail(t)
+
+		vc, err := repo.StoreVerificationCode(ctx, email)
+		if err != nil {
+			t.Fatalf("StoreVerificationCode() error = %v", err)
+		}
+
+		if vc.Email != email {
+			t.Errorf("StoreVerificationCode() email = %q, want %q", vc.Email, email)
+		}
+
+		if vc.Code == "" {
+			t.Error("StoreVerificationCode() code is empty")
+		}
+
+		if vc.CreatedAt.IsZero() {
+			t.Error("StoreVerificationCode() created at is zero")
+		}
+
+		other, err := repo.StoreVerificationCode(ctx, email)
+		if err != nil {
+			t.Fatalf("StoreVerificationCode() second call error = %v", err)
+		}
+
+		if other.Code == vc.Code {
+			t.Errorf("StoreVerificationCode() returned the same code twice: %q", vc.Code)
+		}
+	})
+
+	t.Run("VerificationCode", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email := randEmail(t)
+
+		want, err := repo.StoreVerificationCode(ctx, email)
+		if err != nil {
+			t.Fatalf("StoreVerificationCode() error = %v", err)
+		}
+
+		got, err := repo.VerificationCode(ctx, email, want.Code)
+		if err != nil {
+			t.Fatalf("VerificationCode() error = %v", err)
+		}
+
+		if got.Email != want.Email || got.Code != want.Code {
+			t.Errorf("VerificationCode() = %+v, want %+v", got, want)
+		}
+
+		if !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("VerificationCode() created at = %v, want %v", got.CreatedAt, want.CreatedAt)
+		}
+
+		_, err = repo.VerificationCode(ctx, email, missingCode)
+		if !errors.Is(err, passwordless.ErrVerificationCodeNotFound) {
+			t.Errorf("VerificationCode() with missing code error = %v, want %v", err, passwordless.ErrVerificationCodeNotFound)
+		}
+
+		_, err = repo.VerificationCode(ctx, randEmail(t), want.Code)
+		if !errors.Is(err, passwordless.ErrVerificationCodeNotFound) {
+			t.Errorf("VerificationCode() with other email error = %v, want %v", err, passwordless.ErrVerificationCodeNotFound)
+		}
+	})
+
+	t.Run("DeleteVerificationCode", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email := randEmail(t)
+
+		vc, err := repo.StoreVerificationCode(ctx, email)
+		if err != nil {
+			t.Fatalf("StoreVerificationCode() error = %v", err)
+		}
+
+		deleted, err := repo.DeleteVerificationCode(ctx, email, vc.Code)
+		if err != nil {
+			t.Fatalf("DeleteVerificationCode() error = %v", err)
+		}
+
+		if !deleted {
+			t.Error("DeleteVerificationCode() = false, want true")
+		}
+
+		deleted, err = repo.DeleteVerificationCode(ctx, email, vc.Code)
+		if err != nil {
+			t.Fatalf("DeleteVerificationCode() second call error = %v", err)
+		}
+
+		if deleted {
+			t.Error("DeleteVerificationCode() second call = true, want false")
+		}
+
+		_, err = repo.VerificationCode(ctx, email, vc.Code)
+		if !errors.Is(err, passwordless.ErrVerificationCodeNotFound) {
+			t.Errorf("VerificationCode() after delete error = %v, want %v", err, passwordless.ErrVerificationCodeNotFound)
+		}
+	})
+
+	t.Run("StoreUser", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email, username := randEmail(t), randUsername(t)
+
+		u, err := repo.StoreUser(ctx, email, username)
+		if err != nil {
+			t.Fatalf("StoreUser() error = %v", err)
+		}
+
+		if u.ID == "" {
+			t.Error("StoreUser() id is empty")
+		}
+
+		if u.Email != email || u.Username != username {
+			t.Errorf("StoreUser() = %+v, want email %q and username %q", u, email, username)
+		}
+
+		if u.CreatedAt.IsZero() {
+			t.Error("StoreUser() created at is zero")
+		}
+
+		if u.DisplayName != nil || u.AvatarURL != nil || u.Locale != nil || u.TimeZone != nil || u.PhoneNumber != nil || u.LastLoginAt != nil {
+			t.Errorf("StoreUser() = %+v, want empty profile", u)
+		}
+
+		if u.Status != passwordless.UserStatusActive || u.StatusReason != nil || u.StatusExpiresAt != nil {
+			t.Errorf("StoreUser() = %+v, want active status", u)
+		}
+
+		_, err = repo.StoreUser(ctx, email, randUsername(t))
+		if !errors.Is(err, passwordless.ErrEmailTaken) {
+			t.Errorf("StoreUser() with taken email error = %v, want %v", err, passwordless.ErrEmailTaken)
+		}
+
+		_, err = repo.StoreUser(ctx, strings.ToUpper(email), randUsername(t))
+		if !errors.Is(err, passwordless.ErrEmailTaken) {
+			t.Errorf("StoreUser() with taken email in other case error = %v, want %v", err, passwordless.ErrEmailTaken)
+		}
+
+		_, err = repo.StoreUser(ctx, randEmail(t), username)
+		if !errors.Is(err, passwordless.ErrUsernameTaken) {
+			t.Errorf("StoreUser() with taken username error = %v, want %v", err, passwordless.ErrUsernameTaken)
+		}
+	})
+
+	t.Run("UserExistsByEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		u := storeUser(t, repo)
+
+		exists, err := repo.UserExistsByEmail(ctx, u.Email)
+		if err != nil {
+			t.Fatalf("UserExistsByEmail() error = %v", err)
+		}
+
+		if !exists {
+			t.Error("UserExistsByEmail() = false, want true")
+		}
+
+		exists, err = repo.UserExistsByEmail(ctx, strings.ToUpper(u.Email))
+		if err != nil {
+			t.Fatalf("UserExistsByEmail() in other case error = %v", err)
+		}
+
+		if !exists {
+			t.Error("UserExistsByEmail() in other case = false, want true")
+		}
+
+		exists, err = repo.UserExistsByEmail(ctx, randEmail(t))
+		if err != nil {
+			t.Fatalf("UserExistsByEmail() with missing email error = %v", err)
+		}
+
+		if exists {
+			t.Error("UserExistsByEmail() with missing email = true, want false")
+		}
+	})
+
+	t.Run("UserByEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := storeUser(t, repo)
+
+		got, err := repo.UserByEmail(ctx, want.Email)
+		if err != nil {
+			t.Fatalf("UserByEmail() error = %v", err)
+		}
+
+		assertUser(t, "UserByEmail()", got, want)
+
+		got, err = repo.UserByEmail(ctx, strings.ToUpper(want.Email))
+		if err != nil {
+			t.Fatalf("UserByEmail() in other case error = %v", err)
+		}
+
+		assertUser(t, "UserByEmail() in other case", got, want)
+
+		_, err = repo.UserByEmail(ctx, randEmail(t))
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UserByEmail() with missing email error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+	})
+
+	t.Run("UserByPhoneNumber", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := storeUser(t, repo)
+
+		var err error
+		want, err = repo.UpdateUser(ctx, want.ID, passwordless.UpdateUserParams{PhoneNumber: strPtr(randPhoneNumber(t))})
+		if err != nil {
+			t.Fatalf("UpdateUser() error = %v", err)
+		}
+
+		got, err := repo.UserByPhoneNumber(ctx, *want.PhoneNumber)
+		if err != nil {
+			t.Fatalf("UserByPhoneNumber() error = %v", err)
+		}
+
+		assertUser(t, "UserByPhoneNumber()", got, want)
+
+		_, err = repo.UserByPhoneNumber(ctx, randPhoneNumber(t))
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UserByPhoneNumber() with missing phone number error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+	})
+
+	t.Run("User", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := storeUser(t, repo)
+
+		got, err := repo.User(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("User() error = %v", err)
+		}
+
+		assertUser(t, "User()", got, want)
+
+		_, err = repo.User(ctx, missingUserID)
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("User() with missing id error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+	})
+
+	t.Run("UpdateUser", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := storeUser(t, repo)
+
+		got, err := repo.UpdateUser(ctx, want.ID, passwordless.UpdateUserParams{})
+		if err != nil {
+			t.Fatalf("UpdateUser() without changes error = %v", err)
+		}
+
+		assertUser(t, "UpdateUser() without changes", got, want)
+
+		want.DisplayName = strPtr("John Doe")
+		want.AvatarURL = strPtr("https://example.org/avatar.png")
+		want.Locale = strPtr("es-CL")
+		want.TimeZone = strPtr("America/Santiago")
+		want.PhoneNumber = strPtr(randPhoneNumber(t))
+		got, err = repo.UpdateUser(ctx, want.ID, passwordless.UpdateUserParams{
+			DisplayName: want.DisplayName,
+			AvatarURL:   want.AvatarURL,
+			Locale:      want.Locale,
+			TimeZone:    want.TimeZone,
+			PhoneNumber: want.PhoneNumber,
+		})
+		if err != nil {
+			t.Fatalf("UpdateUser() error = %v", err)
+		}
+
+		assertUser(t, "UpdateUser()", got, want)
+
+		want.AvatarURL = nil
+		want.Locale = strPtr("en")
+		got, err = repo.UpdateUser(ctx, want.ID, passwordless.UpdateUserParams{
+			AvatarURL: strPtr(""),
+			Locale:    want.Locale,
+		})
+		if err != nil {
+			t.Fatalf("UpdateUser() partial error = %v", err)
+		}
+
+		assertUser(t, "UpdateUser() partial", got, want)
+
+		got, err = repo.User(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("User() error = %v", err)
+		}
+
+		assertUser(t, "User() after update", got, want)
+
+		other := storeUser(t, repo)
+		_, err = repo.UpdateUser(ctx, other.ID, passwordless.UpdateUserParams{PhoneNumber: want.PhoneNumber})
+		if !errors.Is(err, passwordless.ErrPhoneNumberTaken) {
+			t.Errorf("UpdateUser() with taken phone number error = %v, want %v", err, passwordless.ErrPhoneNumberTaken)
+		}
+
+		_, err = repo.UpdateUser(ctx, missingUserID, passwordless.UpdateUserParams{DisplayName: strPtr("John Doe")})
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UpdateUser() with missing id error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+
+		_, err = repo.UpdateUser(ctx, missingUserID, passwordless.UpdateUserParams{})
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UpdateUser() with missing id and no changes error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+	})
+
+	t.Run("UpdateUserLastLogin", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		u := storeUser(t, repo)
+
+		lastLoginAt, err := repo.UpdateUserLastLogin(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("UpdateUserLastLogin() error = %v", err)
+		}
+
+		if lastLoginAt.IsZero() {
+			t.Error("UpdateUserLastLogin() = zero time")
+		}
+
+		u, err = repo.User(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("User() error = %v", err)
+		}
+
+		if u.LastLoginAt == nil || !u.LastLoginAt.Equal(lastLoginAt) {
+			t.Errorf("User() last login at = %v, want %v", u.LastLoginAt, lastLoginAt)
+		}
+
+		_, err = repo.UpdateUserLastLogin(ctx, missingUserID)
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UpdateUserLastLogin() with missing id error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+	})
+
+	t.Run("UpdateUserStatus", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := storeUser(t, repo)
+
+		expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		want.Status = passwordless.UserStatusSuspended
+		want.StatusReason = strPtr("spam")
+		want.StatusExpiresAt = &expiresAt
+		got, err := repo.UpdateUserStatus(ctx, want.ID, want.Status, *want.StatusReason, want.StatusExpiresAt)
+		if err != nil {
+			t.Fatalf("UpdateUserStatus() error = %v", err)
+		}
+
+		assertUser(t, "UpdateUserStatus()", got, want)
+
+		got, err = repo.User(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("User() error = %v", err)
+		}
+
+		assertUser(t, "User() after status update", got, want)
+
+		want.Status = passwordless.UserStatusActive
+		want.StatusReason = nil
+		want.StatusExpiresAt = nil
+		got, err = repo.UpdateUserStatus(ctx, want.ID, want.Status, "", nil)
+		if err != nil {
+			t.Fatalf("UpdateUserStatus() reactivation error = %v", err)
+		}
+
+		assertUser(t, "UpdateUserStatus() reactivation", got, want)
+
+		_, err = repo.UpdateUserStatus(ctx, missingUserID, passwordless.UserStatusBanned, "", nil)
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UpdateUserStatus() with missing id error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+	})
+
+	t.Run("UserByUsername", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := storeUser(t, repo)
+
+		got, err := repo.UserByUsername(ctx, want.Username)
+		if err != nil {
+			t.Fatalf("UserByUsername() error = %v", err)
+		}
+
+		assertUser(t, "UserByUsername()", got, want)
+
+		_, err = repo.UserByUsername(ctx, randUsername(t))
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UserByUsername() with missing username error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+	})
+
+	t.Run("UpdateUsername", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := storeUser(t, repo)
+		oldUsername := want.Username
+
+		want.Username = randUsername(t)
+		got, err := repo.UpdateUsername(ctx, want.ID, want.Username)
+		if err != nil {
+			t.Fatalf("UpdateUsername() error = %v", err)
+		}
+
+		assertUser(t, "UpdateUsername()", got, want)
+
+		_, err = repo.UserByUsername(ctx, oldUsername)
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UserByUsername() with old username error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+
+		other := storeUser(t, repo)
+		_, err = repo.UpdateUsername(ctx, want.ID, other.Username)
+		if !errors.Is(err, passwordless.ErrUsernameTaken) {
+			t.Errorf("UpdateUsername() with taken username error = %v, want %v", err, passwordless.ErrUsernameTaken)
+		}
+
+		_, err = repo.UpdateUsername(ctx, missingUserID, randUsername(t))
+		if !errors.Is(err, passwordless.ErrUserNotFound) {
+			t.Errorf("UpdateUsername() with missing id error = %v, want %v", err, passwordless.ErrUserNotFound)
+		}
+	})
+
+	t.Run("PhoneNumberCode", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		u := storeUser(t, repo)
+
+		_, err := repo.PhoneNumberCode(ctx, u.ID)
+		if !errors.Is(err, passwordless.ErrPhoneNumberCodeNotFound) {
+			t.Errorf("PhoneNumberCode() without pending phone number error = %v, want %v", err, passwordless.ErrPhoneNumberCodeNotFound)
+		}
+
+		_, err = repo.StorePhoneNumberCode(ctx, u.ID, randPhoneNumber(t), "012345")
+		if err != nil {
+			t.Fatalf("StorePhoneNumberCode() error = %v", err)
+		}
+
+		want, err := repo.StorePhoneNumberCode(ctx, u.ID, randPhoneNumber(t), "543210")
+		if err != nil {
+			t.Fatalf("StorePhoneNumberCode() second call error = %v", err)
+		}
+
+		if want.UserID != u.ID || want.CreatedAt.IsZero() {
+			t.Errorf("StorePhoneNumberCode() = %+v, want user id %q and created at", want, u.ID)
+		}
+
+		got, err := repo.PhoneNumberCode(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("PhoneNumberCode() error = %v", err)
+		}
+
+		if got.PhoneNumber != want.PhoneNumber || got.Code != want.Code || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("PhoneNumberCode() = %+v, want latest %+v", got, want)
+		}
+
+		after, err := repo.User(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("User() error = %v", err)
+		}
+
+		if after.PhoneNumber != nil {
+			t.Errorf("User() phone number = %q, want pending phone number unset", *after.PhoneNumber)
+		}
+
+		err = repo.DeletePhoneNumberCode(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("DeletePhoneNumberCode() error = %v", err)
+		}
+
+		_, err = repo.PhoneNumberCode(ctx, u.ID)
+		if !errors.Is(err, passwordless.ErrPhoneNumberCodeNotFound) {
+			t.Errorf("PhoneNumberCode() after delete error = %v, want %v", err, passwordless.ErrPhoneNumberCodeNotFound)
+		}
+
+		err = repo.DeletePhoneNumberCode(ctx, u.ID)
+		if err != nil {
+			t.Errorf("DeletePhoneNumberCode() without pending phone number error = %v", err)
+		}
+	})
+
+	t.Run("ReleasedUsername", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		first, second := storeUser(t, repo), storeUser(t, repo)
+		username := randUsername(t)
+
+		_, err := repo.ReleasedUsername(ctx, username)
+		if !errors.Is(err, passwordless.ErrReleasedUsernameNotFound) {
+			t.Errorf("ReleasedUsername() with missing username error = %v, want %v", err, passwordless.ErrReleasedUsernameNotFound)
+		}
+
+		rel, err := repo.StoreReleasedUsername(ctx, first.ID, username)
+		if err != nil {
+			t.Fatalf("StoreReleasedUsername() error = %v", err)
+		}
+
+		if rel.Username != username || rel.UserID != first.ID || rel.ReleasedAt.IsZero() {
+			t.Errorf("StoreReleasedUsername() = %+v, want username %q and user id %q", rel, username, first.ID)
+		}
+
+		want, err := repo.StoreReleasedUsername(ctx, second.ID, username)
+		if err != nil {
+			t.Fatalf("StoreReleasedUsername() second call error = %v", err)
+		}
+
+		got, err := repo.ReleasedUsername(ctx, username)
+		if err != nil {
+			t.Fatalf("ReleasedUsername() error = %v", err)
+		}
+
+		if got.Username != want.Username || got.UserID != want.UserID || !got.ReleasedAt.Equal(want.ReleasedAt) {
+			t.Errorf("ReleasedUsername() = %+v, want latest release %+v", got, want)
+		}
+	})
+
+	t.Run("StoreOutboxMessage", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		to := randEmail(t)
+
+		msg, err := repo.StoreOutboxMessage(ctx, passwordless.OutboxChannelEmail, to, []byte(`{"key":"value"}`), testTraceParent)
+		if err != nil {
+			t.Fatalf("StoreOutboxMessage() error = %v", err)
+		}
+
+		if msg.ID == "" || msg.Channel != passwordless.OutboxChannelEmail || msg.To != to {
+			t.Errorf("StoreOutboxMessage() = %+v, want channel %q and recipient %q", msg, passwordless.OutboxChannelEmail, to)
+		}
+
+		if msg.Status != passwordless.OutboxStatusPending || msg.Attempts != 0 || msg.LastError != nil || msg.DeliveredAt != nil {
+			t.Errorf("StoreOutboxMessage() = %+v, want pending message", msg)
+		}
+
+		if msg.CreatedAt.IsZero() || msg.NextAttemptAt.IsZero() {
+			t.Errorf("StoreOutboxMessage() = %+v, want timestamps", msg)
+		}
+
+		var payload map[string]string
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload["key"] != "value" {
+			t.Errorf("StoreOutboxMessage() payload = %s, want same json", msg.Payload)
+		}
+
+		assertStrPtr(t, "StoreOutboxMessage() trace parent", msg.TraceParent, strPtr(testTraceParent))
+	})
+
+	t.Run("OutboxMessage", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := storeOutboxMessage(t, repo)
+
+		got, err := repo.OutboxMessage(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("OutboxMessage() error = %v", err)
+		}
+
+		if got.ID != want.ID || got.To != want.To || got.Status != want.Status || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("OutboxMessage() = %+v, want %+v", got, want)
+		}
+
+		assertStrPtr(t, "OutboxMessage() trace parent", got.TraceParent, nil)
+
+		_, err = repo.OutboxMessage(ctx, missingOutboxMessageID)
+		if !errors.Is(err, passwordless.ErrOutboxMessageNotFound) {
+			t.Errorf("OutboxMessage() with missing id error = %v, want %v", err, passwordless.ErrOutboxMessageNotFound)
+		}
+	})
+
+	t.Run("ClaimOutboxMessages", func(t *testing.T) {
+		repo := newRepo(t)
+		msg := storeOutboxMessage(t, repo)
+
+		if !claimOutboxMessage(t, repo, msg.ID) {
+			t.Fatal("ClaimOutboxMessages() did not claim pending message")
+		}
+
+		got, err := repo.OutboxMessage(context.Background(), msg.ID)
+		if err != nil {
+			t.Fatalf("OutboxMessage() error = %v", err)
+		}
+
+		if got.Status != passwordless.OutboxStatusPending || got.Attempts != 1 {
+			t.Errorf("OutboxMessage() after claim = %+v, want pending with one attempt", got)
+		}
+
+		if claimOutboxMessage(t, repo, msg.ID) {
+			t.Error("ClaimOutboxMessages() claimed leased message twice")
+		}
+	})
+
+	t.Run("MarkOutboxMessageDelivered", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		msg := storeOutboxMessage(t, repo)
+
+		err := repo.MarkOutboxMessageDelivered(ctx, msg.ID)
+		if err != nil {
+			t.Fatalf("MarkOutboxMessageDelivered() error = %v", err)
+		}
+
+		got, err := repo.OutboxMessage(ctx, msg.ID)
+		if err != nil {
+			t.Fatalf("OutboxMessage() error = %v", err)
+		}
+
+		if got.Status != passwordless.OutboxStatusDelivered || got.Attempts != msg.Attempts || got.DeliveredAt == nil {
+			t.Errorf("OutboxMessage() after delivery = %+v, want delivered", got)
+		}
+
+		if strings.Contains(string(got.Payload), "value") {
+			t.Errorf("OutboxMessage() after delivery payload = %s, want it cleared", got.Payload)
+		}
+
+		if claimOutboxMessage(t, repo, msg.ID) {
+			t.Error("ClaimOutboxMessages() claimed delivered message")
+		}
+
+		err = repo.MarkOutboxMessageDelivered(ctx, missingOutboxMessageID)
+		if !errors.Is(err, passwordless.ErrOutboxMessageNotFound) {
+			t.Errorf("MarkOutboxMessageDelivered() with missing id error = %v, want %v", err, passwordless.ErrOutboxMessageNotFound)
+		}
+	})
+
+	t.Run("RetryOutboxMessage", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		msg := storeOutboxMessage(t, repo)
+
+		err := repo.RetryOutboxMessage(ctx, msg.ID, "connection refused", time.Hour)
+		if err != nil {
+			t.Fatalf("RetryOutboxMessage() error = %v", err)
+		}
+
+		got, err := repo.OutboxMessage(ctx, msg.ID)
+		if err != nil {
+			t.Fatalf("OutboxMessage() error = %v", err)
+		}
+
+		if got.Status != passwordless.OutboxStatusPending || got.Attempts != msg.Attempts || got.LastError == nil || *got.LastError != "connection refused" {
+			t.Errorf("OutboxMessage() after retry = %+v, want pending with last error", got)
+		}
+
+		if !got.NextAttemptAt.After(msg.NextAttemptAt.Add(time.Minute * 50)) {
+			t.Errorf("OutboxMessage() next attempt at = %v, want about an hour after %v", got.NextAttemptAt, msg.NextAttemptAt)
+		}
+
+		if claimOutboxMessage(t, repo, msg.ID) {
+			t.Error("ClaimOutboxMessages() claimed message before its next attempt")
+		}
+
+		err = repo.RetryOutboxMessage(ctx, missingOutboxMessageID, "", time.Second)
+		if !errors.Is(err, passwordless.ErrOutboxMessageNotFound) {
+			t.Errorf("RetryOutboxMessage() with missing id error = %v, want %v", err, passwordless.ErrOutboxMessageNotFound)
+		}
+	})
+
+	t.Run("DeadLetterOutboxMessage", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		msg := storeOutboxMessage(t, repo)
+
+		err := repo.DeadLetterOutboxMessage(ctx, msg.ID, "mailbox unavailable")
+		if err != nil {
+			t.Fatalf("DeadLetterOutboxMessage() error = %v", err)
+		}
+
+		got, err := repo.OutboxMessage(ctx, msg.ID)
+		if err != nil {
+			t.Fatalf("OutboxMessage() error = %v", err)
+		}
+
+		if got.Status != passwordless.OutboxStatusDead || got.Attempts != msg.Attempts || got.LastError == nil || *got.LastError != "mailbox unavailable" {
+			t.Errorf("OutboxMessage() after dead letter = %+v, want dead with last error", got)
+		}
+
+		if claimOutboxMessage(t, repo, msg.ID) {
+			t.Error("ClaimOutboxMessages() claimed dead message")
+		}
+
+		err = repo.DeadLetterOutboxMessage(ctx, missingOutboxMessageID, "")
+		if !errors.Is(err, passwordless.ErrOutboxMessageNotFound) {
+			t.Errorf("DeadLetterOutboxMessage() with missing id error = %v, want %v", err, passwordless.ErrOutboxMessageNotFound)
+		}
+	})
+
+	t.Run("StoreSuppression", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email := randEmail(t)
+
+		s, err := repo.StoreSuppression(ctx, strings.ToUpper(email), passwordless.SuppressionReasonBounce, "")
+		if err != nil {
+			t.Fatalf("StoreSuppression() error = %v", err)
+		}
+
+		if s.Email != email || s.Reason != passwordless.SuppressionReasonBounce || s.Detail != nil || s.CreatedAt.IsZero() {
+			t.Errorf("StoreSuppression() = %+v, want lowercased email %q and bounce reason", s, email)
+		}
+
+		updated, err := repo.StoreSuppression(ctx, email, passwordless.SuppressionReasonComplaint, "abuse")
+		if err != nil {
+			t.Fatalf("StoreSuppression() second call error = %v", err)
+		}
+
+		if updated.Reason != passwordless.SuppressionReasonComplaint || !updated.CreatedAt.Equal(s.CreatedAt) {
+			t.Errorf("StoreSuppression() second call = %+v, want updated reason and same creation time", updated)
+		}
+
+		assertStrPtr(t, "StoreSuppression() detail", updated.Detail, strPtr("abuse"))
+	})
+
+	t.Run("Suppression", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		email := randEmail(t)
+
+		_, err := repo.Suppression(ctx, email)
+		if !errors.Is(err, passwordless.ErrSuppressionNotFound) {
+			t.Errorf("Suppression() with missing email error = %v, want %v", err, passwordless.ErrSuppressionNotFound)
+		}
+
+		want, err := repo.StoreSuppression(ctx, email, passwordless.SuppressionReasonBounce, "550 5.1.1 user unknown")
+		if err != nil {
+			t.Fatalf("StoreSuppression() error = %v", err)
+		}
+
+		got, err := repo.Suppression(ctx, strings.ToUpper(email))
+		if err != nil {
+			t.Fatalf("Suppression() error = %v", err)
+		}
+
+		if got.Email != want.Email || got.Reason != want.Reason || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("Suppression() = %+v, want %+v", got, want)
+		}
+
+		assertStrPtr(t, "Suppression() detail", got.Detail, want.Detail)
+	})
+
+	t.Run("ExecuteTx", func(t *testing.T) {
+		t.Run("commit", func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+			email, username := randEmail(t), randUsername(t)
+
+			err := repo.ExecuteTx(ctx, func(ctx context.Context) error {
+				_, err := repo.StoreUser(ctx, email, username)
+				if err != nil {
+					return err
+				}
+
+				exists, err := repo.UserExistsByEmail(ctx, email)
+				if err != nil {
+					return err
+				}
+
+				if !exists {
+					t.Error("UserExistsByEmail() inside tx = false, want true")
+				}
+
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ExecuteTx() error = %v", err)
+			}
+
+			exists, err := repo.UserExistsByEmail(ctx, email)
+			if err != nil {
+				t.Fatalf("UserExistsByEmail() error = %v", err)
+			}
+
+			if !exists {
+				t.Error("UserExistsByEmail() after commit = false, want true")
+			}
+		})
+
+		t.Run("rollback", func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+			email := randEmail(t)
+
+			err := repo.ExecuteTx(ctx, func(ctx context.Context) error {
+				_, err := repo.StoreUser(ctx, email, randUsername(t))
+				if err != nil {
+					return err
+				}
+
+				_, err = repo.StoreVerificationCode(ctx, email)
+				if err != nil {
+					return err
+				}
+
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatalf("ExecuteTx() error = %v, want %v", err, errRollback)
+			}
+
+			exists, err := repo.UserExistsByEmail(ctx, email)
+			if err != nil {
+				t.Fatalf("UserExistsByEmail() error = %v", err)
+			}
+
+			if exists {
+				t.Error("UserExistsByEmail() after rollback = true, want false")
+			}
+		})
+
+		t.Run("error propagation", func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+			u := storeUser(t, repo)
+
+			err := repo.ExecuteTx(ctx, func(ctx context.Context) error {
+				_, err := repo.StoreUser(ctx, u.Email, randUsername(t))
+				return err
+			})
+			if !errors.Is(err, passwordless.ErrEmailTaken) {
+				t.Errorf("ExecuteTx() error = %v, want %v", err, passwordless.ErrEmailTaken)
+			}
+		})
+	})
+}
+
+func assertUser(t *testing.T, name string, got, want passwordless.User) {
+	t.Helper()
+
+	if got.ID != want.ID || got.Email != want.Email || got.Username != want.Username {
+		t.Errorf("%s = %+v, want %+v", name, got, want)
+	}
+
+	assertStrPtr(t, name+" display name", got.DisplayName, want.DisplayName)
+	assertStrPtr(t, name+" avatar URL", got.AvatarURL, want.AvatarURL)
+	assertStrPtr(t, name+" locale", got.Locale, want.Locale)
+	assertStrPtr(t, name+" time zone", got.TimeZone, want.TimeZone)
+	assertStrPtr(t, name+" phone number", got.PhoneNumber, want.PhoneNumber)
+
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("%s created at = %v, want %v", name, got.CreatedAt, want.CreatedAt)
+	}
+
+	assertTimePtr(t, name+" last login at", got.LastLoginAt, want.LastLoginAt)
+
+	if got.Status != want.Status {
+		t.Errorf("%s status = %q, want %q", name, got.Status, want.Status)
+	}
+
+	assertStrPtr(t, name+" status reason", got.StatusReason, want.StatusReason)
+	assertTimePtr(t, name+" status expires at", got.StatusExpiresAt, want.StatusExpiresAt)
+}
+
+func assertTimePtr(t *testing.T, name string, got, want *time.Time) {
+	t.Helper()
+
+	if (got == nil) != (want == nil) || (got != nil && !got.Equal(*want)) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func assertStrPtr(t *testing.T, name string, got, want *string) {
+	t.Helper()
+
+	if got == nil && want == nil {
+		return
+	}
+
+	if got == nil || want == nil || *got != *want {
+		t.Errorf("%s = %s, want %s", name, fmtStrPtr(got), fmtStrPtr(want))
+	}
+}
+
+func fmtStrPtr(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+
+	return strconv.Quote(*s)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func storeOutboxMessage(t *testing.T, repo passwordless.Repository) passwordless.OutboxMessage {
+	t.Helper()
+
+	msg, err := repo.StoreOutboxMessage(context.Background(), passwordless.OutboxChannelEmail, randEmail(t), []byte(`{}`), "")
+	if err != nil {
+		t.Fatalf("StoreOutboxMessage() error = %v", err)
+	}
+
+	return msg
+}
+
+// claimOutboxMessage claims every due message
+// and tells whether the one with the given ID was among them.
+// Other tests sharing the storage must not rely on claims.
+func claimOutboxMessage(t *testing.T, repo passwordless.Repository, id string) bool {
+	t.Helper()
+
+	msgs, err := repo.ClaimOutboxMessages(context.Background(), 1000, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimOutboxMessages() error = %v", err)
+	}
+
+	for _, msg := range msgs {
+		if msg.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func storeUser(t *testing.T, repo passwordless.Repository) passwordless.User {
+	t.Helper()
+
+	u, err := repo.StoreUser(context.Background(), randEmail(t), randUsername(t))
+	if err != nil {
+		t.Fatalf("StoreUser() error = %v", err)
+	}
+
+	return u
+}
+
+func randEmail(t *testing.T) string {
+	return "user_" + randHex(t, 8) + "@example.org"
+}
+
+func randUsername(t *testing.T) string {
+	return "user_" + randHex(t, 6)
+}
+
+// randPhoneNumber returns an E.164 phone number.
+func randPhoneNumber(t *testing.T) string {
+	t.Helper()
+
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatalf("could not read random bytes: %v", err)
+	}
+
+	digits := make([]byte, len(b))
+	for i, c := range b {
+		digits[i] = '0' + c%10
+	}
+
+	return "+1" + string(digits)
+}
+
+func randHex(t *testing.T, n int) string {
+	t.Helper()
+
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatalf("could not read random bytes: %v", err)
+	}
+
+	return hex.EncodeToString(b)
+}
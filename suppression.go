@@ -0,0 +1,69 @@
+package passwordless
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const maxSuppressionDetailLength = 512
+
+type SuppressionReason string
+
+const (
+	// SuppressionReasonBounce means the address permanently failed delivery.
+	SuppressionReasonBounce SuppressionReason = "bounce"
+	// SuppressionReasonComplaint means the recipient marked a message as spam.
+	SuppressionReasonComplaint SuppressionReason = "complaint"
+)
+
+func (r SuppressionReason) valid() bool {
+	return r == SuppressionReasonBounce || r == SuppressionReasonComplaint
+}
+
+// Suppression is an email address no more messages are sent to.
+type Suppression struct {
+	Email     string
+	Reason    SuppressionReason
+	Detail    *string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SuppressEmail adds the given email address to the suppression list
+// so SendMagicLink refuses it from now on.
+// Detail is a free form explanation like the SMTP diagnostic code.
+func (svc *Service) SuppressEmail(ctx context.Context, email string, reason SuppressionReason, detail string) (Suppression, error) {
+	var s Suppression
+
+	email, err := svc.EmailNormalization.normalizeEmail(email)
+	if err != nil {
+		return s, err
+	}
+
+	if !reason.valid() {
+		return s, ErrInvalidSuppressionReason
+	}
+
+	detail = strings.TrimSpace(detail)
+	if len(detail) > maxSuppressionDetailLength {
+		detail = detail[:maxSuppressionDetailLength]
+	}
+
+	return svc.Repository.StoreSuppression(ctx, email, reason, detail)
+}
+
+// checkSuppression returns ErrEmailSuppressed
+// if the given normalized email is in the suppression list.
+func (svc *Service) checkSuppression(ctx context.Context, email string) error {
+	_, err := svc.Repository.Suppression(ctx, email)
+	if err == ErrSuppressionNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ErrEmailSuppressed
+}
@@ -0,0 +1,58 @@
+package passwordless
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+func TestService_suppression(t *testing.T) {
+	tests := []struct {
+		name       string
+		suppressed bool
+		wantErr    error
+		wantQueued int
+	}{
+		{name: "not suppressed", wantQueued: 1},
+		{name: "suppressed", suppressed: true, wantErr: ErrEmailSuppressed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepo()
+			svc := newTestService(t, repo)
+			ctx := context.Background()
+
+			u := storeTestUser(t, repo, "john")
+			if tt.suppressed {
+				// Emails are matched case insensitively.
+				if _, err := svc.SuppressEmail(ctx, "John@Example.org", SuppressionReasonBounce, "550 no such user"); err != nil {
+					t.Fatalf("SuppressEmail() error = %v", err)
+				}
+			}
+
+			t.Run("SendMagicLink", func(t *testing.T) {
+				before := len(repo.outboxMessages())
+				if _, err := svc.SendMagicLink(ctx, u.Email, "https://example.org/callback"); err != tt.wantErr {
+					t.Fatalf("SendMagicLink() error = %v, want %v", err, tt.wantErr)
+				}
+
+				if got := len(repo.outboxMessages()) - before; got != tt.wantQueued {
+					t.Errorf("queued = %d, want %d", got, tt.wantQueued)
+				}
+			})
+
+			t.Run("notifyUser", func(t *testing.T) {
+				before := len(repo.outboxMessages())
+				// Notifications skip suppressed addresses silently.
+				if err := svc.notifyUser(ctx, u, notification.WelcomeData{Username: u.Username}); err != nil {
+					t.Fatalf("notifyUser() error = %v", err)
+				}
+
+				if got := len(repo.outboxMessages()) - before; got != tt.wantQueued {
+					t.Errorf("queued = %d, want %d", got, tt.wantQueued)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentRoute starts a server span named after route for each request,
+// continuing the trace from the incoming traceparent header if any.
+// route should be the pattern the request matches.
+func InstrumentRoute(route string, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, route, otelhttp.WithSpanOptions(
+		trace.WithAttributes(semconv.HTTPRouteKey.String(route)),
+	))
+}
@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/nicolasparada/go-passwordless-demo/tracing"
+
+// InstrumentService starts a span for each service call.
+// Calls that don't take a context are left untraced.
+func InstrumentService(svc transport.Service) transport.Service {
+	return &service{Service: svc, tracer: otel.Tracer(instrumentationName)}
+}
+
+type service struct {
+	transport.Service
+	tracer trace.Tracer
+}
+
+func (s *service) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "Service."+method)
+}
+
+func (s *service) SendMagicLink(ctx context.Context, to, redirectURI string) (passwordless.OutboxMessage, error) {
+	ctx, span := s.start(ctx, "SendMagicLink")
+	msg, err := s.Service.SendMagicLink(ctx, to, redirectURI)
+	end(span, err)
+	return msg, err
+}
+
+func (s *service) OutboxMessage(ctx context.Context, id string) (passwordless.OutboxMessage, error) {
+	ctx, span := s.start(ctx, "OutboxMessage")
+	msg, err := s.Service.OutboxMessage(ctx, id)
+	end(span, err)
+	return msg, err
+}
+
+func (s *service) VerifyMagicLink(ctx context.Context, email, code string, username *string) (passwordless.Auth, error) {
+	ctx, span := s.start(ctx, "VerifyMagicLink")
+	auth, err := s.Service.VerifyMagicLink(ctx, email, code, username)
+	end(span, err)
+	return auth, err
+}
+
+func (s *service) ParseAuthToken(ctx context.Context, token string) (string, error) {
+	ctx, span := s.start(ctx, "ParseAuthToken")
+	userID, err := s.Service.ParseAuthToken(ctx, token)
+	end(span, err)
+	return userID, err
+}
+
+func (s *service) AuthUser(ctx context.Context) (passwordless.User, error) {
+	ctx, span := s.start(ctx, "AuthUser")
+	user, err := s.Service.AuthUser(ctx)
+	end(span, err)
+	return user, err
+}
+
+func (s *service) UpdateUser(ctx context.Context, params passwordless.UpdateUserParams) (passwordless.User, error) {
+	ctx, span := s.start(ctx, "UpdateUser")
+	user, err := s.Service.UpdateUser(ctx, params)
+	end(span, err)
+	return user, err
+}
+
+func (s *service) ChangeUsername(ctx context.Context, username string) (passwordless.User, error) {
+	ctx, span := s.start(ctx, "ChangeUsername")
+	user, err := s.Service.ChangeUsername(ctx, username)
+	end(span, err)
+	return user, err
+}
+
+func (s *service) ConfirmPhoneNumber(ctx context.Context, code string) (passwordless.User, error) {
+	ctx, span := s.start(ctx, "ConfirmPhoneNumber")
+	user, err := s.Service.ConfirmPhoneNumber(ctx, code)
+	end(span, err)
+	return user, err
+}
+
+func (s *service) UserByUsername(ctx context.Context, username string) (passwordless.User, error) {
+	ctx, span := s.start(ctx, "UserByUsername")
+	user, err := s.Service.UserByUsername(ctx, username)
+	end(span, err)
+	return user, err
+}
+
+func (s *service) SuppressEmail(ctx context.Context, email string, reason passwordless.SuppressionReason, detail string) (passwordless.Suppression, error) {
+	ctx, span := s.start(ctx, "SuppressEmail")
+	sup, err := s.Service.SuppressEmail(ctx, email, reason, detail)
+	end(span, err)
+	return sup, err
+}
+
+// end records err, if any, and ends span.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,127 @@
+// Package tracing exports OpenTelemetry traces of HTTP requests,
+// service calls, database queries and notification deliveries.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+const defaultServiceName = "passwordless"
+
+// Exporter is where spans get exported to.
+type Exporter string
+
+const (
+	// ExporterOTLP exports spans to an OpenTelemetry collector over OTLP/HTTP.
+	ExporterOTLP Exporter = "otlp"
+	// ExporterStdout writes spans as JSON. Only meant for local development.
+	ExporterStdout Exporter = "stdout"
+)
+
+// ParseExporter parses an exporter. Empty means tracing is disabled.
+func ParseExporter(s string) (Exporter, error) {
+	switch e := Exporter(s); e {
+	case "", ExporterOTLP, ExporterStdout:
+		return e, nil
+	}
+
+	return "", fmt.Errorf("unknown trace exporter %q", s)
+}
+
+type Options struct {
+	Exporter Exporter
+	// OTLPEndpoint is the host:port of the collector.
+	// Defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable
+	// or localhost:4318.
+	OTLPEndpoint string
+	// OTLPInsecure sends spans to the collector over plain HTTP.
+	OTLPInsecure bool
+	// ServiceName defaults to "passwordless".
+	ServiceName string
+	// Writer is where ExporterStdout writes to. Defaults to os.Stdout.
+	Writer io.Writer
+}
+
+// Setup sets the global tracer provider and the W3C trace context propagator.
+// Call the returned function to flush pending spans before exiting.
+func Setup(ctx context.Context, opts Options) (shutdown func(context.Context) error, err error) {
+	exp, err := newExporter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(opts.serviceName()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("could not create trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return tp.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, error) {
+	switch opts.Exporter {
+	case ExporterOTLP:
+		var oo []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			oo = append(oo, otlptracehttp.WithEndpoint(opts.OTLPEndpoint))
+		}
+		if opts.OTLPInsecure {
+			oo = append(oo, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, oo...)
+		if err != nil {
+			return nil, fmt.Errorf("could not create otlp trace exporter: %w", err)
+		}
+
+		return exp, nil
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(opts.writer()))
+		if err != nil {
+			return nil, fmt.Errorf("could not create stdout trace exporter: %w", err)
+		}
+
+		return exp, nil
+	}
+
+	return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+}
+
+func (opts Options) serviceName() string {
+	if opts.ServiceName != "" {
+		return opts.ServiceName
+	}
+
+	return defaultServiceName
+}
+
+func (opts Options) writer() io.Writer {
+	if opts.Writer != nil {
+		return opts.Writer
+	}
+
+	return os.Stdout
+}
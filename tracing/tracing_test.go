@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+	httptransport "github.com/nicolasparada/go-passwordless-demo/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type serviceStub struct {
+	transport.Service
+	err error
+}
+
+func (s serviceStub) VerifyMagicLink(ctx context.Context, email, code string, username *string) (passwordless.Auth, error) {
+	return passwordless.Auth{}, s.err
+}
+
+func (s serviceStub) ParseAuthToken(ctx context.Context, token string) (string, error) {
+	return "user-id", nil
+}
+
+func (s serviceStub) AuthUser(ctx context.Context) (passwordless.User, error) {
+	return passwordless.User{ID: "user-id"}, nil
+}
+
+// recordSpans sets a global tracer provider recording spans in memory.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+	})
+
+	return rec
+}
+
+func TestTracing(t *testing.T) {
+	rec := recordSpans(t)
+
+	svc := InstrumentService(serviceStub{err: passwordless.ErrVerificationCodeExpired})
+	h := InstrumentRoute("/api/verify-magic-link", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = svc.VerifyMagicLink(r.Context(), "", "", nil)
+	}))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/api/verify-magic-link", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans; got %d", len(spans))
+	}
+
+	svcSpan, routeSpan := spans[0], spans[1]
+
+	t.Run("route span continues the incoming trace", func(t *testing.T) {
+		if got := routeSpan.Name(); got != "/api/verify-magic-link" {
+			t.Errorf("want route span name %q; got %q", "/api/verify-magic-link", got)
+		}
+		if got := routeSpan.SpanContext().TraceID().String(); got != traceID {
+			t.Errorf("want trace ID %q; got %q", traceID, got)
+		}
+	})
+
+	t.Run("service span is a child of the route span", func(t *testing.T) {
+		if got := svcSpan.Name(); got != "Service.VerifyMagicLink" {
+			t.Errorf("want service span name %q; got %q", "Service.VerifyMagicLink", got)
+		}
+		if svcSpan.Parent().SpanID() != routeSpan.SpanContext().SpanID() {
+			t.Errorf("want service span parent %s; got %s", routeSpan.SpanContext().SpanID(), svcSpan.Parent().SpanID())
+		}
+	})
+
+	t.Run("service span records the error", func(t *testing.T) {
+		if got := svcSpan.Status().Code; got != codes.Error {
+			t.Errorf("want status %v; got %v", codes.Error, got)
+		}
+		if got := svcSpan.Status().Description; got != passwordless.ErrVerificationCodeExpired.Error() {
+			t.Errorf("want status description %q; got %q", passwordless.ErrVerificationCodeExpired.Error(), got)
+		}
+	})
+}
+
+func TestTracing_middlewares(t *testing.T) {
+	rec := recordSpans(t)
+
+	h := httptransport.NewHandler(InstrumentService(serviceStub{}), log.New(io.Discard, "", 0), httptransport.Options{
+		InstrumentRoute: InstrumentRoute,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/auth-user", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range rec.Ended() {
+		spans[span.Name()] = span
+	}
+
+	routeSpan, ok := spans["/api/auth-user"]
+	if !ok {
+		t.Fatalf("missing route span; got %v", spans)
+	}
+
+	for _, name := range []string{"Service.ParseAuthToken", "Service.AuthUser"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("missing %s span", name)
+			continue
+		}
+
+		if span.Parent().SpanID() != routeSpan.SpanContext().SpanID() {
+			t.Errorf("%s span is not a child of the route span", name)
+		}
+	}
+}
+
+func TestParseExporter(t *testing.T) {
+	for _, s := range []string{"", "otlp", "stdout"} {
+		if _, err := ParseExporter(s); err != nil {
+			t.Errorf("ParseExporter(%q): %v", s, err)
+		}
+	}
+
+	if _, err := ParseExporter("jaeger"); err == nil {
+		t.Error("want error for unknown exporter")
+	}
+}
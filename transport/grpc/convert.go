@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"time"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/transport/grpc/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func outboxMessageToPB(msg passwordless.OutboxMessage) *pb.OutboxMessage {
+	return &pb.OutboxMessage{
+		Id:            msg.ID,
+		Channel:       string(msg.Channel),
+		Status:        string(msg.Status),
+		Attempts:      int32(msg.Attempts),
+		NextAttemptAt: timestamppb.New(msg.NextAttemptAt),
+		CreatedAt:     timestamppb.New(msg.CreatedAt),
+		DeliveredAt:   timestampPtrToPB(msg.DeliveredAt),
+	}
+}
+
+func authToPB(auth passwordless.Auth) *pb.Auth {
+	return &pb.Auth{
+		Token:     auth.Token,
+		ExpiresAt: timestamppb.New(auth.ExpiresAt),
+		User:      userToPB(auth.User),
+	}
+}
+
+func userToPB(u passwordless.User) *pb.User {
+	return &pb.User{
+		Id:              u.ID,
+		Email:           u.Email,
+		Username:        u.Username,
+		DisplayName:     u.DisplayName,
+		AvatarUrl:       u.AvatarURL,
+		Locale:          u.Locale,
+		TimeZone:        u.TimeZone,
+		PhoneNumber:     u.PhoneNumber,
+		CreatedAt:       timestamppb.New(u.CreatedAt),
+		LastLoginAt:     timestampPtrToPB(u.LastLoginAt),
+		Status:          string(u.Status),
+		StatusReason:    u.StatusReason,
+		StatusExpiresAt: timestampPtrToPB(u.StatusExpiresAt),
+	}
+}
+
+func userProfileToPB(p passwordless.UserProfile) *pb.UserProfile {
+	return &pb.UserProfile{
+		Id:          p.ID,
+		Username:    p.Username,
+		DisplayName: p.DisplayName,
+		AvatarUrl:   p.AvatarURL,
+	}
+}
+
+func timestampPtrToPB(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	return timestamppb.New(*t)
+}
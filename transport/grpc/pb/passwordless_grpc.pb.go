@@ -0,0 +1,270 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: passwordless.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// PasswordlessClient is the client API for Passwordless service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PasswordlessClient interface {
+	// SendMagicLink queues a magic link to the given email address,
+	// or by SMS if it's a phone number.
+	// Send an "accept-language" metadata to pick its language.
+	SendMagicLink(ctx context.Context, in *SendMagicLinkRequest, opts ...grpc.CallOption) (*OutboxMessage, error)
+	// GetOutboxMessage returns the delivery status of an outbox message.
+	GetOutboxMessage(ctx context.Context, in *GetOutboxMessageRequest, opts ...grpc.CallOption) (*OutboxMessage, error)
+	// VerifyMagicLink verifies the code sent in a magic link
+	// and returns an auth token. Sending a username creates the user
+	// if it doesn't exist yet.
+	VerifyMagicLink(ctx context.Context, in *VerifyMagicLinkRequest, opts ...grpc.CallOption) (*Auth, error)
+	// GetAuthUser returns the authenticated user.
+	GetAuthUser(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*User, error)
+	// GetUser returns the public profile of the user with the given username.
+	// Released usernames still find their last user.
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*UserProfile, error)
+}
+
+type passwordlessClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPasswordlessClient(cc grpc.ClientConnInterface) PasswordlessClient {
+	return &passwordlessClient{cc}
+}
+
+func (c *passwordlessClient) SendMagicLink(ctx context.Context, in *SendMagicLinkRequest, opts ...grpc.CallOption) (*OutboxMessage, error) {
+	out := new(OutboxMessage)
+	err := c.cc.Invoke(ctx, "/passwordless.v1.Passwordless/SendMagicLink", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordlessClient) GetOutboxMessage(ctx context.Context, in *GetOutboxMessageRequest, opts ...grpc.CallOption) (*OutboxMessage, error) {
+	out := new(OutboxMessage)
+	err := c.cc.Invoke(ctx, "/passwordless.v1.Passwordless/GetOutboxMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordlessClient) VerifyMagicLink(ctx context.Context, in *VerifyMagicLinkRequest, opts ...grpc.CallOption) (*Auth, error) {
+	out := new(Auth)
+	err := c.cc.Invoke(ctx, "/passwordless.v1.Passwordless/VerifyMagicLink", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordlessClient) GetAuthUser(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/passwordless.v1.Passwordless/GetAuthUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordlessClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*UserProfile, error) {
+	out := new(UserProfile)
+	err := c.cc.Invoke(ctx, "/passwordless.v1.Passwordless/GetUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PasswordlessServer is the server API for Passwordless service.
+// All implementations must embed UnimplementedPasswordlessServer
+// for forward compatibility
+type PasswordlessServer interface {
+	// SendMagicLink queues a magic link to the given email address,
+	// or by SMS if it's a phone number.
+	// Send an "accept-language" metadata to pick its language.
+	SendMagicLink(context.Context, *SendMagicLinkRequest) (*OutboxMessage, error)
+	// GetOutboxMessage returns the delivery status of an outbox message.
+	GetOutboxMessage(context.Context, *GetOutboxMessageRequest) (*OutboxMessage, error)
+	// VerifyMagicLink verifies the code sent in a magic link
+	// and returns an auth token. Sending a username creates the user
+	// if it doesn't exist yet.
+	VerifyMagicLink(context.Context, *VerifyMagicLinkRequest) (*Auth, error)
+	// GetAuthUser returns the authenticated user.
+	GetAuthUser(context.Context, *emptypb.Empty) (*User, error)
+	// GetUser returns the public profile of the user with the given username.
+	// Released usernames still find their last user.
+	GetUser(context.Context, *GetUserRequest) (*UserProfile, error)
+	mustEmbedUnimplementedPasswordlessServer()
+}
+
+// UnimplementedPasswordlessServer must be embedded to have forward compatible implementations.
+type UnimplementedPasswordlessServer struct {
+}
+
+func (UnimplementedPasswordlessServer) SendMagicLink(context.Context, *SendMagicLinkRequest) (*OutboxMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendMagicLink not implemented")
+}
+func (UnimplementedPasswordlessServer) GetOutboxMessage(context.Context, *GetOutboxMessageRequest) (*OutboxMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOutboxMessage not implemented")
+}
+func (UnimplementedPasswordlessServer) VerifyMagicLink(context.Context, *VerifyMagicLinkRequest) (*Auth, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyMagicLink not implemented")
+}
+func (UnimplementedPasswordlessServer) GetAuthUser(context.Context, *emptypb.Empty) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAuthUser not implemented")
+}
+func (UnimplementedPasswordlessServer) GetUser(context.Context, *GetUserRequest) (*UserProfile, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedPasswordlessServer) mustEmbedUnimplementedPasswordlessServer() {}
+
+// UnsafePasswordlessServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PasswordlessServer will
+// result in compilation errors.
+type UnsafePasswordlessServer interface {
+	mustEmbedUnimplementedPasswordlessServer()
+}
+
+func RegisterPasswordlessServer(s grpc.ServiceRegistrar, srv PasswordlessServer) {
+	s.RegisterService(&Passwordless_ServiceDesc, srv)
+}
+
+func _Passwordless_SendMagicLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendMagicLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServer).SendMagicLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/passwordless.v1.Passwordless/SendMagicLink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServer).SendMagicLink(ctx, req.(*SendMagicLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Passwordless_GetOutboxMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOutboxMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServer).GetOutboxMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/passwordless.v1.Passwordless/GetOutboxMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServer).GetOutboxMessage(ctx, req.(*GetOutboxMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Passwordless_VerifyMagicLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyMagicLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServer).VerifyMagicLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/passwordless.v1.Passwordless/VerifyMagicLink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServer).VerifyMagicLink(ctx, req.(*VerifyMagicLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Passwordless_GetAuthUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServer).GetAuthUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/passwordless.v1.Passwordless/GetAuthUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServer).GetAuthUser(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Passwordless_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/passwordless.v1.Passwordless/GetUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Passwordless_ServiceDesc is the grpc.ServiceDesc for Passwordless service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Passwordless_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "passwordless.v1.Passwordless",
+	HandlerType: (*PasswordlessServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendMagicLink",
+			Handler:    _Passwordless_SendMagicLink_Handler,
+		},
+		{
+			MethodName: "GetOutboxMessage",
+			Handler:    _Passwordless_GetOutboxMessage_Handler,
+		},
+		{
+			MethodName: "VerifyMagicLink",
+			Handler:    _Passwordless_VerifyMagicLink_Handler,
+		},
+		{
+			MethodName: "GetAuthUser",
+			Handler:    _Passwordless_GetAuthUser_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _Passwordless_GetUser_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "passwordless.proto",
+}
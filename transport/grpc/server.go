@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+	"github.com/nicolasparada/go-passwordless-demo/transport/grpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// NewServer creates a gRPC server with the Passwordless service registered.
+// Calls are authenticated with an "authorization: Bearer <token>" metadata.
+func NewServer(svc transport.Service, l *log.Logger, opts ...grpc.ServerOption) *grpc.Server {
+	s := &server{service: svc, logger: l}
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.withAuthUserID))
+	srv := grpc.NewServer(opts...)
+	pb.RegisterPasswordlessServer(srv, s)
+	return srv
+}
+
+type server struct {
+	pb.UnimplementedPasswordlessServer
+	service transport.Service
+	logger  *log.Logger
+}
+
+func (s *server) withAuthUserID(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		if !strings.HasPrefix(auth, "Bearer ") {
+			continue
+		}
+
+		authUserID, err := s.service.ParseAuthToken(ctx, auth[7:])
+		if err != nil {
+			return nil, s.status(err)
+		}
+
+		ctx = context.WithValue(ctx, passwordless.KeyAuthUserID, authUserID)
+		break
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *server) SendMagicLink(ctx context.Context, req *pb.SendMagicLinkRequest) (*pb.OutboxMessage, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = context.WithValue(ctx, passwordless.KeyAcceptLanguage, strings.Join(md.Get("accept-language"), ","))
+	msg, err := s.service.SendMagicLink(ctx, req.To, req.RedirectUri)
+	if err != nil {
+		return nil, s.status(err)
+	}
+
+	return outboxMessageToPB(msg), nil
+}
+
+func (s *server) GetOutboxMessage(ctx context.Context, req *pb.GetOutboxMessageRequest) (*pb.OutboxMessage, error) {
+	msg, err := s.service.OutboxMessage(ctx, req.Id)
+	if err != nil {
+		return nil, s.status(err)
+	}
+
+	return outboxMessageToPB(msg), nil
+}
+
+func (s *server) VerifyMagicLink(ctx context.Context, req *pb.VerifyMagicLinkRequest) (*pb.Auth, error) {
+	var username *string
+	if req.Username != nil {
+		username = emptyStringPtr(strings.TrimSpace(*req.Username))
+	}
+
+	auth, err := s.service.VerifyMagicLink(ctx, req.Email, req.Code, username)
+	if err != nil {
+		return nil, s.status(err)
+	}
+
+	return authToPB(auth), nil
+}
+
+func (s *server) GetAuthUser(ctx context.Context, _ *emptypb.Empty) (*pb.User, error) {
+	u, err := s.service.AuthUser(ctx)
+	if err != nil {
+		return nil, s.status(err)
+	}
+
+	return userToPB(u), nil
+}
+
+func (s *server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.UserProfile, error) {
+	u, err := s.service.UserByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, s.status(err)
+	}
+
+	return userProfileToPB(u.Profile()), nil
+}
+
+func emptyStringPtr(s string) *string {
+	if s != "" {
+		return &s
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"testing"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+	"github.com/nicolasparada/go-passwordless-demo/transport/grpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type serviceStub struct {
+	transport.Service
+}
+
+func (serviceStub) ParseAuthToken(ctx context.Context, token string) (string, error) {
+	if token != "valid" {
+		return "", passwordless.ErrUnauthenticated
+	}
+
+	return "user-id", nil
+}
+
+func (serviceStub) AuthUser(ctx context.Context) (passwordless.User, error) {
+	authUserID, ok := ctx.Value(passwordless.KeyAuthUserID).(string)
+	if !ok {
+		return passwordless.User{}, passwordless.ErrUnauthenticated
+	}
+
+	return passwordless.User{ID: authUserID, Username: "jane"}, nil
+}
+
+func (serviceStub) UserByUsername(ctx context.Context, username string) (passwordless.User, error) {
+	if username == "broken" {
+		return passwordless.User{}, errors.New("connection reset")
+	}
+
+	if username == "jane" {
+		return passwordless.User{ID: "user-id", Email: "jane@example.org", Username: "jane"}, nil
+	}
+
+	return passwordless.User{}, passwordless.ErrUserNotFound
+}
+
+func (serviceStub) SendMagicLink(ctx context.Context, to, redirectURI string) (passwordless.OutboxMessage, error) {
+	if lang, _ := ctx.Value(passwordless.KeyAcceptLanguage).(string); lang != "es" {
+		return passwordless.OutboxMessage{}, errors.New("missing accept language")
+	}
+
+	return passwordless.OutboxMessage{ID: "msg-id", Channel: passwordless.OutboxChannelEmail}, nil
+}
+
+func newTestClient(t *testing.T) pb.PasswordlessClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(serviceStub{}, log.New(io.Discard, "", 0))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewPasswordlessClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	client := newTestClient(t)
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		_, err := client.GetAuthUser(context.Background(), &emptypb.Empty{})
+		if got := status.Code(err); got != codes.Unauthenticated {
+			t.Errorf("GetAuthUser() code = %s, want %s", got, codes.Unauthenticated)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+		_, err := client.GetAuthUser(ctx, &emptypb.Empty{})
+		if got := status.Code(err); got != codes.Unauthenticated {
+			t.Errorf("GetAuthUser() code = %s, want %s", got, codes.Unauthenticated)
+		}
+	})
+
+	t.Run("authenticated", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer valid")
+		u, err := client.GetAuthUser(ctx, &emptypb.Empty{})
+		if err != nil {
+			t.Fatalf("GetAuthUser() error = %v", err)
+		}
+
+		if u.Id != "user-id" || u.Username != "jane" || u.DisplayName != nil {
+			t.Errorf("GetAuthUser() = %v", u)
+		}
+	})
+
+	t.Run("user profile", func(t *testing.T) {
+		p, err := client.GetUser(context.Background(), &pb.GetUserRequest{Username: "jane"})
+		if err != nil {
+			t.Fatalf("GetUser() error = %v", err)
+		}
+
+		if p.Id != "user-id" || p.Username != "jane" {
+			t.Errorf("GetUser() = %v", p)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := client.GetUser(context.Background(), &pb.GetUserRequest{Username: "john"})
+		if got := status.Code(err); got != codes.NotFound {
+			t.Errorf("GetUser() code = %s, want %s", got, codes.NotFound)
+		}
+	})
+
+	t.Run("internal", func(t *testing.T) {
+		_, err := client.GetUser(context.Background(), &pb.GetUserRequest{Username: "broken"})
+		s := status.Convert(err)
+		if s.Code() != codes.Internal || s.Message() != "internal server error" {
+			t.Errorf("GetUser() status = %v, want hidden internal error", s)
+		}
+	})
+
+	t.Run("accept language", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "accept-language", "es")
+		msg, err := client.SendMagicLink(ctx, &pb.SendMagicLinkRequest{To: "jane@example.org"})
+		if err != nil {
+			t.Fatalf("SendMagicLink() error = %v", err)
+		}
+
+		if msg.Id != "msg-id" || msg.Channel != "email" {
+			t.Errorf("SendMagicLink() = %v", msg)
+		}
+	})
+}
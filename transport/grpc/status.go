@@ -0,0 +1,36 @@
+package grpc
+
+import (
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var kindCode = map[passwordless.ErrorKind]codes.Code{
+	passwordless.ErrorKindInternal:         codes.Internal,
+	passwordless.ErrorKindInvalidArgument:  codes.InvalidArgument,
+	passwordless.ErrorKindPermissionDenied: codes.PermissionDenied,
+	passwordless.ErrorKindNotFound:         codes.NotFound,
+	passwordless.ErrorKindUnauthenticated:  codes.Unauthenticated,
+	passwordless.ErrorKindAlreadyExists:    codes.AlreadyExists,
+}
+
+// status converts err into a gRPC status.
+// Internal errors are logged and their details not exposed.
+func (s *server) status(err error) error {
+	code := err2code(err)
+	if code != codes.Internal {
+		return status.Error(code, err.Error())
+	}
+
+	s.logger.Println(err)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+func err2code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+
+	return kindCode[passwordless.ErrorKindOf(err)]
+}
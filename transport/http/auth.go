@@ -3,27 +3,43 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"html/template"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/web"
 )
 
 func (h *handler) withAuthUserID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		auth := r.Header.Get("Authorization")
 		if strings.HasPrefix(auth, "Bearer ") {
-			authUserID, err := h.service.ParseAuthToken(auth[7:])
+			ctx := r.Context()
+			authUserID, err := h.service.ParseAuthToken(ctx, auth[7:])
 			if err != nil {
-				h.respondErr(w, err)
+				h.respondErr(w, r, err)
 				return
 			}
 
-			ctx := r.Context()
 			ctx = context.WithValue(ctx, passwordless.KeyAuthUserID, authUserID)
 			r = r.WithContext(ctx)
+		} else if c, err := r.Cookie(sessionCookieName); err == nil && h.cookieSessions {
+			if err := checkCSRF(r); err != nil {
+				h.respondErr(w, r, err)
+				return
+			}
+
+			// A stale session is just ignored
+			// so public endpoints keep working.
+			ctx := r.Context()
+			authUserID, err := h.service.ParseAuthToken(ctx, c.Value)
+			if err == nil {
+				ctx = context.WithValue(ctx, passwordless.KeyAuthUserID, authUserID)
+				r = r.WithContext(ctx)
+			}
 		}
 
 		next.ServeHTTP(w, r)
@@ -32,6 +48,7 @@ func (h *handler) withAuthUserID(next http.Handler) http.Handler {
 
 type sendMagicLinkReqBody struct {
 	Email       string
+	PhoneNumber string
 	RedirectURI string
 }
 
@@ -47,37 +64,129 @@ func (h *handler) sendMagicLink(w http.ResponseWriter, r *http.Request) {
 	var reqBody sendMagicLinkReqBody
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		h.respondErr(w, errBadRequest)
+		h.respondErr(w, r, errBadRequest)
 		return
 	}
 
+	to := reqBody.Email
+	if reqBody.PhoneNumber != "" {
+		to = reqBody.PhoneNumber
+	}
+
 	ctx := r.Context()
-	err = h.service.SendMagicLink(ctx, reqBody.Email, reqBody.RedirectURI)
+	ctx = context.WithValue(ctx, passwordless.KeyAcceptLanguage, r.Header.Get("Accept-Language"))
+	msg, err := h.service.SendMagicLink(ctx, to, reqBody.RedirectURI)
 	if err != nil {
-		h.respondErr(w, err)
+		h.respondErr(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	h.respond(w, r, msg, http.StatusAccepted)
 }
 
-func (h *handler) verifyMagicLink(w http.ResponseWriter, r *http.Request) {
+func (h *handler) outboxMessage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Allow", "GET")
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	id := strings.TrimPrefix(r.URL.Path, "/api/outbox-messages/")
+
+	ctx := r.Context()
+	msg, err := h.service.OutboxMessage(ctx, id)
+	if err != nil {
+		h.respondErr(w, r, err)
+		return
+	}
+
+	h.respond(w, r, msg, http.StatusOK)
+}
+
+var verifyMagicLinkTmpl = template.Must(template.ParseFS(web.Files, "template/verify-magic-link.html.tmpl"))
+
+type verifyMagicLinkPageData struct {
+	Email       string
+	Code        string
+	RedirectURI string
+	Username    string
+	CSRFToken   string
+}
+
+// verifyMagicLinkHandler serves the magic link.
+// Mail scanners prefetch every link they find,
+// so GET only renders a page to confirm the login
+// and the magic link is actually verified when that page POSTs back.
+func (h *handler) verifyMagicLinkHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.verifyMagicLinkPage(w, r)
+		case http.MethodPost:
+			h.verifyMagicLink(w, r)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (h *handler) verifyMagicLinkPage(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	redirectURI, err := h.service.ValidateRedirectURI(q.Get("redirect_uri"))
+	_, err := h.service.ValidateRedirectURI(q.Get("redirect_uri"))
 	if err != nil {
-		h.respondErr(w, err)
+		h.respondErr(w, r, paramErr{err, "redirect_uri"})
 		return
 	}
 
-	email := q.Get("email")
-	code := q.Get("code")
-	username := emptyStringPtr(strings.TrimSpace(q.Get("username")))
+	var csrf string
+	if h.cookieSessions {
+		csrf, err = csrfToken(w, r)
+		if err != nil {
+			h.respondErr(w, r, err)
+			return
+		}
+	}
+
+	// The page holds the verification code.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = verifyMagicLinkTmpl.Execute(w, verifyMagicLinkPageData{
+		Email:       q.Get("email"),
+		Code:        q.Get("code"),
+		RedirectURI: q.Get("redirect_uri"),
+		Username:    q.Get("username"),
+		CSRFToken:   csrf,
+	})
+	if err != nil {
+		h.log(r).Printf("could not render verify magic link template: %v\n", err)
+	}
+}
+
+func (h *handler) verifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		h.respondErr(w, r, errBadRequest)
+		return
+	}
+
+	redirectURI, err := h.service.ValidateRedirectURI(r.PostForm.Get("redirect_uri"))
+	if err != nil {
+		h.respondErr(w, r, paramErr{err, "redirect_uri"})
+		return
+	}
+
+	// Prevents logging someone in to the account of somebody else.
+	if h.cookieSessions {
+		if err := checkCSRF(r); err != nil {
+			h.respondErr(w, r, err)
+			return
+		}
+	}
+
+	email := r.PostForm.Get("email")
+	code := r.PostForm.Get("code")
+	username := emptyStringPtr(strings.TrimSpace(r.PostForm.Get("username")))
 
 	ctx := r.Context()
 	auth, err := h.service.VerifyMagicLink(ctx, email, code, username)
@@ -85,10 +194,15 @@ func (h *handler) verifyMagicLink(w http.ResponseWriter, r *http.Request) {
 		err == passwordless.ErrInvalidUsername ||
 		err == passwordless.ErrUsernameTaken
 	if isRetryableError {
-		h.redirectWithData(w, r, redirectURI, url.Values{
-			"error":     []string{err.Error()},
-			"retry_uri": []string{r.RequestURI},
-		})
+		// Retry through the confirm page again.
+		retryURI := url.URL{Path: r.URL.Path, RawQuery: url.Values{
+			"email":        []string{email},
+			"code":         []string{code},
+			"redirect_uri": []string{r.PostForm.Get("redirect_uri")},
+		}.Encode()}
+		data := errData(h.problem(r, err))
+		data.Set("retry_uri", retryURI.String())
+		h.redirectWithData(w, r, redirectURI, data)
 		return
 	}
 	if err != nil {
@@ -96,24 +210,160 @@ func (h *handler) verifyMagicLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.redirectWithData(w, r, redirectURI, url.Values{
-		"token":         []string{auth.Token},
+	data := url.Values{
 		"expires_at":    []string{auth.ExpiresAt.Format(time.RFC3339Nano)},
 		"user.id":       []string{auth.User.ID},
 		"user.email":    []string{auth.User.Email},
 		"user.username": []string{auth.User.Username},
-	})
+	}
+	if h.cookieSessions {
+		err = h.setSession(w, auth)
+		if err != nil {
+			h.redirectWithErr(w, r, redirectURI, err)
+			return
+		}
+	} else {
+		data.Set("token", auth.Token)
+	}
+
+	h.redirectWithData(w, r, redirectURI, data)
 }
 
 func (h *handler) authUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	u, err := h.service.AuthUser(ctx)
 	if err != nil {
-		h.respondErr(w, err)
+		h.respondErr(w, r, err)
+		return
+	}
+
+	h.respond(w, r, u, http.StatusOK)
+}
+
+type updateUserReqBody struct {
+	DisplayName *string
+	AvatarURL   *string
+	Locale      *string
+	TimeZone    *string
+	PhoneNumber *string
+}
+
+func (h *handler) updateUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		w.Header().Set("Allow", "PATCH")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var reqBody updateUserReqBody
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		h.respondErr(w, r, errBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	u, err := h.service.UpdateUser(ctx, passwordless.UpdateUserParams{
+		DisplayName: reqBody.DisplayName,
+		AvatarURL:   reqBody.AvatarURL,
+		Locale:      reqBody.Locale,
+		TimeZone:    reqBody.TimeZone,
+		PhoneNumber: reqBody.PhoneNumber,
+	})
+	if err != nil {
+		h.respondErr(w, r, err)
+		return
+	}
+
+	h.respond(w, r, u, http.StatusOK)
+}
+
+type changeUsernameReqBody struct {
+	Username string
+}
+
+func (h *handler) changeUsername(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.Header().Set("Allow", "PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var reqBody changeUsernameReqBody
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		h.respondErr(w, r, errBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	u, err := h.service.ChangeUsername(ctx, strings.TrimSpace(reqBody.Username))
+	if err != nil {
+		h.respondErr(w, r, err)
+		return
+	}
+
+	h.respond(w, r, u, http.StatusOK)
+}
+
+type confirmPhoneNumberReqBody struct {
+	Code string
+}
+
+func (h *handler) confirmPhoneNumber(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var reqBody confirmPhoneNumberReqBody
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		h.respondErr(w, r, errBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	u, err := h.service.ConfirmPhoneNumber(ctx, strings.TrimSpace(reqBody.Code))
+	if err != nil {
+		h.respondErr(w, r, err)
+		return
+	}
+
+	h.respond(w, r, u, http.StatusOK)
+}
+
+func (h *handler) user(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := strings.TrimPrefix(r.URL.Path, "/api/users/")
+
+	ctx := r.Context()
+	u, err := h.service.UserByUsername(ctx, username)
+	if err != nil {
+		h.respondErr(w, r, err)
+		return
+	}
+
+	// Looked up by a released username.
+	// Redirect to the current one.
+	if u.Username != username {
+		http.Redirect(w, r, "/api/users/"+url.PathEscape(u.Username), http.StatusFound)
 		return
 	}
 
-	h.respond(w, u, http.StatusOK)
+	h.respond(w, r, u.Profile(), http.StatusOK)
 }
 
 func emptyStringPtr(s string) *string {
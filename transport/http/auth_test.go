@@ -0,0 +1,152 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+)
+
+func TestUser(t *testing.T) {
+	h := &handler{service: &serviceStub{}, logger: log.New(io.Discard, "", 0)}
+	rec := httptest.NewRecorder()
+	h.user(rec, httptest.NewRequest(http.MethodGet, "/api/users/jane", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var got map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"email", "phoneNumber", "lastLoginAt", "status", "statusReason", "statusExpiresAt"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("private %q field exposed", k)
+		}
+	}
+
+	if got["username"] != "jane" {
+		t.Errorf("username = %v, want %q", got["username"], "jane")
+	}
+}
+
+func TestVerifyMagicLinkHandler(t *testing.T) {
+	magicLink := url.Values{
+		"email":        []string{"jane@example.org"},
+		"code":         []string{"00000000-0000-4000-8000-000000000000"},
+		"redirect_uri": []string{"https://example.org/callback"},
+	}
+	newPost := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/verify-magic-link", strings.NewReader(magicLink.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	fragment := func(t *testing.T, rec *httptest.ResponseRecorder) url.Values {
+		t.Helper()
+		loc, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if loc.Host != "example.org" || loc.Path != "/callback" || loc.RawQuery != "" {
+			t.Errorf("location = %q, want the redirect URI with a fragment", loc)
+		}
+
+		data, err := url.ParseQuery(loc.EscapedFragment())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return data
+	}
+
+	t.Run("get", func(t *testing.T) {
+		svc := &serviceStub{}
+		h := NewHandler(svc, log.New(io.Discard, "", 0), Options{})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/verify-magic-link?"+magicLink.Encode(), nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+
+		if svc.verified {
+			t.Error("VerifyMagicLink() called on GET")
+		}
+
+		if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("cache control = %q, want %q", cc, "no-store")
+		}
+
+		body := rec.Body.String()
+		if !strings.Contains(body, `method="POST"`) || !strings.Contains(body, magicLink.Get("code")) {
+			t.Errorf("body = %s, want a form posting the code back", body)
+		}
+	})
+
+	t.Run("post", func(t *testing.T) {
+		svc := &serviceStub{}
+		h := NewHandler(svc, log.New(io.Discard, "", 0), Options{})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newPost())
+
+		if rec.Code != http.StatusFound {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusFound)
+		}
+
+		if !svc.verified {
+			t.Error("VerifyMagicLink() not called on POST")
+		}
+
+		data := fragment(t, rec)
+		if data.Get("token") != testAuthToken || data.Get("user.id") != "user-id" || data.Get("user.username") != "jane" {
+			t.Errorf("fragment = %v, want auth data", data)
+		}
+	})
+
+	t.Run("post retry", func(t *testing.T) {
+		svc := &serviceStub{verifyErr: passwordless.ErrUserNotFound}
+		h := NewHandler(svc, log.New(io.Discard, "", 0), Options{})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newPost())
+
+		if rec.Code != http.StatusFound {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusFound)
+		}
+
+		data := fragment(t, rec)
+		if data.Get("error") != "user_not_found" {
+			t.Errorf("error = %q, want %q", data.Get("error"), "user_not_found")
+		}
+
+		retryURI, err := url.Parse(data.Get("retry_uri"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if retryURI.Path != "/api/verify-magic-link" || !reflect.DeepEqual(retryURI.Query(), magicLink) {
+			t.Errorf("retry uri = %q, want the magic link", retryURI)
+		}
+	})
+
+	t.Run("post error", func(t *testing.T) {
+		svc := &serviceStub{verifyErr: passwordless.ErrVerificationCodeExpired}
+		h := NewHandler(svc, log.New(io.Discard, "", 0), Options{})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newPost())
+
+		data := fragment(t, rec)
+		if data.Get("error") != "verification_code_expired" || data.Has("retry_uri") || data.Has("token") {
+			t.Errorf("fragment = %v, want only the error", data)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultCORSMaxAge = time.Minute * 10
+
+var (
+	defaultCORSAllowedHeaders = []string{"Authorization", "Content-Type", "Accept-Language", csrfHeader, requestIDHeader}
+	corsAllowedMethods        = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch}
+	corsExposedHeaders        = []string{requestIDHeader, "Allow", "WWW-Authenticate"}
+)
+
+// CORSOptions configures cross-origin requests to the API.
+// Only the service trusted origins are allowed,
+// the same ones allowed as redirect URIs.
+type CORSOptions struct {
+	// AllowedHeaders defaults to the ones the API reads.
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies along.
+	// Only useful for front ends in the same site
+	// since cookies are SameSite.
+	AllowCredentials bool
+	// MaxAge of preflight responses. Defaults to 10 minutes.
+	MaxAge time.Duration
+}
+
+func (o CORSOptions) allowedHeaders() []string {
+	if len(o.AllowedHeaders) != 0 {
+		return o.AllowedHeaders
+	}
+
+	return defaultCORSAllowedHeaders
+}
+
+func (o CORSOptions) maxAge() time.Duration {
+	if o.MaxAge > 0 {
+		return o.MaxAge
+	}
+
+	return defaultCORSMaxAge
+}
+
+// withCORS adds CORS headers for trusted origins
+// and answers their preflight requests.
+func (h *handler) withCORS(opts CORSOptions) func(http.Handler) http.Handler {
+	allowedHeaders := strings.Join(opts.allowedHeaders(), ", ")
+	allowedMethods := strings.Join(corsAllowedMethods, ", ")
+	exposedHeaders := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.maxAge().Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			if origin == "" || !h.service.IsTrustedOrigin(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if opts.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !isPreflight {
+				w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	h := &handler{service: &serviceStub{}}
+	srv := h.withCORS(CORSOptions{AllowCredentials: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	t.Run("preflight", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/api/send-magic-link", nil)
+		req.Header.Set("Origin", "https://app.example.org")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+		}
+
+		want := map[string]string{
+			"Access-Control-Allow-Origin":      "https://app.example.org",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Allow-Methods":     "GET, POST, PUT, PATCH",
+			"Access-Control-Max-Age":           "600",
+		}
+		for k, v := range want {
+			if got := rec.Header().Get(k); got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/send-magic-link", nil)
+		req.Header.Set("Origin", "https://app.example.org")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusTeapot {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusTeapot)
+		}
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.org" {
+			t.Errorf("Access-Control-Allow-Origin = %q", got)
+		}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/api/send-magic-link", nil)
+		req.Header.Set("Origin", "https://evil.example")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+		}
+
+		if rec.Code != http.StatusTeapot {
+			t.Errorf("status = %d, want it passed through", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/notification/bounce"
+)
+
+const maxEmailEventsBodySize = 1 << 20
+
+// emailEvents receives bounce and complaint notifications
+// and suppresses the email addresses that should not be emailed anymore.
+// It accepts either a generic JSON event, or an array of them,
+// a whole DSN or feedback report email as message/rfc822,
+// or just its multipart/report body.
+// Only complaints and permanent bounces suppress addresses.
+func (h *handler) emailEvents(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		_, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(secret)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="email-events"`)
+			h.respondErr(w, r, passwordless.ErrUnauthenticated)
+			return
+		}
+
+		defer r.Body.Close()
+
+		events, err := decodeEmailEvents(r.Header.Get("Content-Type"), io.LimitReader(r.Body, maxEmailEventsBodySize))
+		if err != nil {
+			h.respondErr(w, r, errBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		for _, ev := range events {
+			var reason passwordless.SuppressionReason
+			switch {
+			case ev.Type == bounce.EventTypeComplaint:
+				reason = passwordless.SuppressionReasonComplaint
+			case ev.Type == bounce.EventTypeBounce && ev.Permanent:
+				reason = passwordless.SuppressionReasonBounce
+			default:
+				// Soft bounces may succeed later.
+				continue
+			}
+
+			_, err := h.service.SuppressEmail(ctx, ev.Email, reason, ev.Detail)
+			if err == passwordless.ErrInvalidEmail {
+				h.log(r).Printf("ignoring %s of invalid email %q\n", reason, ev.Email)
+				continue
+			}
+
+			if err != nil {
+				h.respondErr(w, r, err)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func decodeEmailEvents(contentType string, body io.Reader) ([]bounce.Event, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case "application/json":
+		var raw json.RawMessage
+		if err := json.NewDecoder(body).Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			var events []bounce.Event
+			err := json.Unmarshal(raw, &events)
+			return events, err
+		}
+
+		var ev bounce.Event
+		err := json.Unmarshal(raw, &ev)
+		return []bounce.Event{ev}, err
+	case "message/rfc822":
+		return bounce.ParseMessage(body)
+	case "multipart/report":
+		return bounce.ParseReport(contentType, body)
+	}
+
+	return nil, errors.New("unsupported email events content type")
+}
@@ -10,34 +10,121 @@ import (
 	"net/url"
 	"strings"
 
-	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/notification/mailbox"
 	"github.com/nicolasparada/go-passwordless-demo/transport"
 )
 
 var errBadRequest = errors.New("bad request")
 
-func NewHandler(svc transport.Service, l *log.Logger) http.Handler {
-	h := &handler{service: svc, logger: l}
-	api := http.NewServeMux()
+type Options struct {
+	// DevMailbox enables the /dev/mailbox page to browse its messages.
+	// Never set it in production since it exposes everyone's magic links.
+	DevMailbox *mailbox.Sender
+	// EmailEventsSecret enables /api/email-events to receive bounces and complaints.
+	// Senders must use it as the HTTP basic auth password.
+	EmailEventsSecret string
+	// CookieSessions makes logins set an HttpOnly session cookie
+	// instead of handing the auth token to the redirect URI.
+	// Requests authenticated with it must send a CSRF token
+	// to change anything. Bearer tokens keep working.
+	// Logging out only clears the cookies,
+	// tokens are not revoked server-side.
+	CookieSessions bool
+	// CORS configures cross-origin requests to the API
+	// from the service trusted origins.
+	CORS CORSOptions
+	// SecurityHeaders configures the security headers of every response.
+	SecurityHeaders SecurityHeadersOptions
+	// InstrumentRoute, if set, wraps the whole handler once per route.
+	// The route is the pattern the request matches,
+	// so middleware responses get instrumented under it too.
+	InstrumentRoute func(route string, next http.Handler) http.Handler
+}
+
+func NewHandler(svc transport.Service, l *log.Logger, opts Options) http.Handler {
+	h := &handler{service: svc, logger: l, cookieSessions: opts.CookieSessions}
+	api := &router{ServeMux: http.NewServeMux()}
 	api.HandleFunc("/api/send-magic-link", h.sendMagicLink)
-	api.HandleFunc("/api/verify-magic-link", h.verifyMagicLink)
+	api.HandleFunc("/api/outbox-messages/", h.outboxMessage)
+	api.Handle("/api/verify-magic-link", h.verifyMagicLinkHandler())
 	api.HandleFunc("/api/auth-user", h.authUser)
+	api.HandleFunc("/api/me", h.updateUser)
+	api.HandleFunc("/api/me/username", h.changeUsername)
+	api.HandleFunc("/api/me/phone-number/confirm", h.confirmPhoneNumber)
+	api.HandleFunc("/api/users/", h.user)
+	api.HandleFunc("/api/openapi.json", h.openAPI)
+	if opts.CookieSessions {
+		api.HandleFunc("/api/logout", h.logout)
+	}
+	if opts.EmailEventsSecret != "" {
+		api.Handle("/api/email-events", h.emailEvents(opts.EmailEventsSecret))
+	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/api/", h.withAuthUserID(api))
+	mux := &router{ServeMux: http.NewServeMux()}
+	mux.Handle("/api/", h.withCORS(opts.CORS)(h.withAuthUserID(api)))
+	if opts.DevMailbox != nil {
+		mb := h.devMailboxHandler(opts.DevMailbox)
+		mux.Handle("/dev/mailbox", mb)
+		mux.Handle("/dev/mailbox/", mb)
+	}
 	mux.Handle("/", h.staticHandler())
-	return mux
+
+	var out http.Handler = h.withRequestID(h.withAccessLog(h.withRecovery(withSecurityHeaders(opts.SecurityHeaders)(mux))))
+	if opts.InstrumentRoute != nil {
+		out = instrumentRoutes(out, opts.InstrumentRoute, api, mux)
+	}
+	return out
+}
+
+// router is an http.ServeMux keeping track of its routes.
+type router struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.ServeMux.Handle(pattern, handler)
+}
+
+func (rt *router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// instrumentRoutes instruments the whole of next by the route of each request.
+// That way responses from middlewares, like 401s or CORS preflights, get instrumented too.
+// Routers are matched in order, so nested ones go first.
+func instrumentRoutes(next http.Handler, instrument func(route string, next http.Handler) http.Handler, routers ...*router) http.Handler {
+	handlers := map[string]http.Handler{}
+	for _, rt := range routers {
+		for _, pattern := range rt.patterns {
+			handlers[pattern] = instrument(pattern, next)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rt := range routers {
+			_, pattern := rt.Handler(r)
+			if h, ok := handlers[pattern]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
 }
 
 type handler struct {
-	service transport.Service
-	logger  *log.Logger
+	service        transport.Service
+	logger         *log.Logger
+	cookieSessions bool
 }
 
-func (h *handler) respond(w http.ResponseWriter, v interface{}, statusCode int) {
+func (h *handler) respond(w http.ResponseWriter, r *http.Request, v interface{}, statusCode int) {
 	b, err := json.Marshal(v)
 	if err != nil {
-		h.respondErr(w, fmt.Errorf("could not json marshall http response body: %w", err))
+		h.respondErr(w, r, fmt.Errorf("could not json marshall http response body: %w", err))
 		return
 	}
 
@@ -45,30 +132,51 @@ func (h *handler) respond(w http.ResponseWriter, v interface{}, statusCode int)
 	w.WriteHeader(statusCode)
 	_, err = w.Write(b)
 	if err != nil && !errors.Is(err, context.Canceled) {
-		h.logger.Printf("could not write http response: %v\n", err)
+		h.log(r).Printf("could not write http response: %v\n", err)
 	}
 }
 
-func (h *handler) respondErr(w http.ResponseWriter, err error) {
-	statusCode := err2code(err)
-	if statusCode != http.StatusInternalServerError {
-		http.Error(w, err.Error(), statusCode)
+func (h *handler) respondErr(w http.ResponseWriter, r *http.Request, err error) {
+	h.respondProblem(w, r, h.problem(r, err))
+}
+
+func (h *handler) respondProblem(w http.ResponseWriter, r *http.Request, p problem) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		h.log(r).Printf("could not json marshall problem: %v\n", err)
+		http.Error(w, p.Detail, p.Status)
 		return
 	}
 
-	h.logger.Println(err)
-	http.Error(w, "internal server error", statusCode)
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	_, err = w.Write(b)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		h.log(r).Printf("could not write http response: %v\n", err)
+	}
 }
 
+// redirectWithErr sends the error code and description
+// in the hash fragment of the redirect URI.
 func (h *handler) redirectWithErr(w http.ResponseWriter, r *http.Request, uri *url.URL, err error) {
-	statusCode := err2code(err)
-	if statusCode != http.StatusInternalServerError {
-		h.redirectWithData(w, r, uri, url.Values{"error": []string{err.Error()}})
-		return
+	h.redirectWithData(w, r, uri, errData(h.problem(r, err)))
+}
+
+// problem is err2problem logging internal errors.
+func (h *handler) problem(r *http.Request, err error) problem {
+	p := err2problem(err)
+	if p.Status == http.StatusInternalServerError {
+		h.log(r).Println(err)
 	}
 
-	h.logger.Println(err)
-	h.redirectWithData(w, r, uri, url.Values{"error": []string{"internal server error"}})
+	return p
+}
+
+func errData(p problem) url.Values {
+	return url.Values{
+		"error":             []string{p.Code},
+		"error_description": []string{p.Detail},
+	}
 }
 
 func (h *handler) redirectWithData(w http.ResponseWriter, r *http.Request, uri *url.URL, data url.Values) {
@@ -80,33 +188,3 @@ func (h *handler) redirectWithData(w http.ResponseWriter, r *http.Request, uri *
 	location = strings.Replace(location, "?", "#", 1)
 	http.Redirect(w, r, location, http.StatusFound)
 }
-
-func err2code(err error) int {
-	if err == nil {
-		return http.StatusOK
-	}
-
-	switch err {
-	case errBadRequest:
-		return http.StatusBadRequest
-	case passwordless.ErrInvalidEmail,
-		passwordless.ErrInvalidRedirectURI,
-		passwordless.ErrInvalidVerificationCode,
-		passwordless.ErrInvalidUsername:
-		return http.StatusUnprocessableEntity
-	case passwordless.ErrUntrustedRedirectURI:
-		return http.StatusForbidden
-	case passwordless.ErrVerificationCodeNotFound,
-		passwordless.ErrUserNotFound:
-		return http.StatusNotFound
-	case passwordless.ErrVerificationCodeExpired:
-		return http.StatusUnauthorized
-	case passwordless.ErrEmailTaken,
-		passwordless.ErrUsernameTaken:
-		return http.StatusConflict
-	case passwordless.ErrUnauthenticated:
-		return http.StatusUnauthorized
-	}
-
-	return http.StatusInternalServerError
-}
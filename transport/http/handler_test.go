@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/internal/httputil"
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+)
+
+const (
+	testAuthToken = "valid-token"
+	testCSRFToken = "csrf-token"
+)
+
+// serviceStub is the service shared by the handler tests.
+// Methods it doesn't stub panic through the nil embedded service.
+type serviceStub struct {
+	transport.Service
+	// verifyErr is returned by VerifyMagicLink when set.
+	verifyErr error
+	verified  bool
+}
+
+func (s *serviceStub) ParseAuthToken(ctx context.Context, token string) (string, error) {
+	if token != testAuthToken {
+		return "", passwordless.ErrUnauthenticated
+	}
+
+	return "user-id", nil
+}
+
+func (s *serviceStub) IsTrustedOrigin(origin string) bool {
+	return origin == "https://app.example.org"
+}
+
+func (s *serviceStub) ValidateRedirectURI(rawurl string) (*url.URL, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || !u.IsAbs() {
+		return nil, passwordless.ErrInvalidRedirectURI
+	}
+
+	return u, nil
+}
+
+func (s *serviceStub) VerifyMagicLink(ctx context.Context, email, code string, username *string) (passwordless.Auth, error) {
+	s.verified = true
+	if s.verifyErr != nil {
+		return passwordless.Auth{}, s.verifyErr
+	}
+
+	return passwordless.Auth{
+		Token:     testAuthToken,
+		ExpiresAt: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		User:      passwordless.User{ID: "user-id", Email: email, Username: "jane"},
+	}, nil
+}
+
+func (s *serviceStub) UpdateUser(ctx context.Context, params passwordless.UpdateUserParams) (passwordless.User, error) {
+	authUserID, ok := ctx.Value(passwordless.KeyAuthUserID).(string)
+	if !ok {
+		return passwordless.User{}, passwordless.ErrUnauthenticated
+	}
+
+	return passwordless.User{ID: authUserID}, nil
+}
+
+func (s *serviceStub) UserByUsername(ctx context.Context, username string) (passwordless.User, error) {
+	phone := "+56912345678"
+	return passwordless.User{
+		ID:          "c5b1a6b0-7d0b-4b6e-9d4b-1f6c3f0e2a11",
+		Email:       "jane@example.org",
+		Username:    "jane",
+		PhoneNumber: &phone,
+		Status:      passwordless.UserStatusActive,
+	}, nil
+}
+
+func TestNewHandler_instrumentRoute(t *testing.T) {
+	var route string
+	var status int
+	h := NewHandler(&serviceStub{}, log.New(io.Discard, "", 0), Options{
+		InstrumentRoute: func(r string, next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				rec := &httputil.ResponseRecorder{ResponseWriter: w}
+				next.ServeHTTP(rec, req)
+				route, status = r, rec.StatusCode()
+			})
+		},
+	})
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/api/send-magic-link", nil)
+	preflight.Header.Set("Origin", "https://app.example.org")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+	invalidToken := httptest.NewRequest(http.MethodGet, "/api/auth-user", nil)
+	invalidToken.Header.Set("Authorization", "Bearer invalid")
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantRoute  string
+		wantStatus int
+	}{
+		{name: "cors preflight", req: preflight, wantRoute: "/api/send-magic-link", wantStatus: http.StatusNoContent},
+		{name: "invalid token", req: invalidToken, wantRoute: "/api/auth-user", wantStatus: http.StatusUnauthorized},
+		{name: "unmatched api path", req: httptest.NewRequest(http.MethodGet, "/api/nope", nil), wantRoute: "/api/", wantStatus: http.StatusNotFound},
+		{name: "api route", req: httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil), wantRoute: "/api/openapi.json", wantStatus: http.StatusOK},
+		{name: "static", req: httptest.NewRequest(http.MethodGet, "/", nil), wantRoute: "/", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, status = "", 0
+			h.ServeHTTP(httptest.NewRecorder(), tt.req)
+
+			if route != tt.wantRoute {
+				t.Errorf("route = %q, want %q", route, tt.wantRoute)
+			}
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
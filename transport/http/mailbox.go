@@ -0,0 +1,74 @@
+package http
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification/mailbox"
+	"github.com/nicolasparada/go-passwordless-demo/web"
+)
+
+var devMailboxTmpl = template.Must(template.ParseFS(web.Files, "template/dev/mailbox.html.tmpl"))
+
+type mailboxMessage struct {
+	mailbox.Message
+	Content mailbox.Content
+}
+
+type mailboxPageData struct {
+	Messages []mailboxMessage
+	Selected *mailboxMessage
+}
+
+// devMailboxHandler serves /dev/mailbox to browse
+// the messages kept by the given development sender.
+func (h *handler) devMailboxHandler(mb *mailbox.Sender) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		msgs, err := mb.Messages()
+		if err != nil {
+			h.respondErr(w, r, err)
+			return
+		}
+
+		var data mailboxPageData
+		for _, msg := range msgs {
+			c, err := msg.Content()
+			if err != nil {
+				h.log(r).Printf("could not parse dev mailbox message %s: %v\n", msg.ID, err)
+			}
+
+			data.Messages = append(data.Messages, mailboxMessage{Message: msg, Content: c})
+		}
+
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/dev/mailbox"), "/")
+		for i := range data.Messages {
+			if data.Messages[i].ID == id {
+				data.Selected = &data.Messages[i]
+				break
+			}
+		}
+
+		if id != "" && data.Selected == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if id == "" && len(data.Messages) != 0 {
+			data.Selected = &data.Messages[0]
+		}
+
+		w.Header().Set("Content-Security-Policy", devMailboxContentSecurityPolicy)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = devMailboxTmpl.Execute(w, data)
+		if err != nil {
+			h.log(r).Printf("could not render dev mailbox template: %v\n", err)
+		}
+	})
+}
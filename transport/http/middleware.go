@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/internal/httputil"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+var keyLogger = struct{ name string }{name: "key-logger"}
+
+// withRequestID propagates the X-Request-ID header of the request,
+// or a new one when missing, to the response and the request context.
+// Both the request logger and the service prefix their lines with it.
+func (h *handler) withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		logger := log.New(h.logger.Writer(), fmt.Sprintf("%srequest_id=%s ", h.logger.Prefix(), id), h.logger.Flags())
+		ctx := r.Context()
+		ctx = context.WithValue(ctx, passwordless.KeyRequestID, id)
+		ctx = context.WithValue(ctx, keyLogger, logger)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// log returns the logger of the request.
+func (h *handler) log(r *http.Request) *log.Logger {
+	if logger, ok := r.Context().Value(keyLogger).(*log.Logger); ok {
+		return logger
+	}
+
+	return h.logger
+}
+
+// withAccessLog logs a line per request once it's served.
+func (h *handler) withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &httputil.ResponseRecorder{ResponseWriter: w}
+		defer func() {
+			h.log(r).Printf("method=%s path=%q status=%d bytes=%d duration=%s remote_addr=%s user_agent=%q\n",
+				r.Method, r.URL.Path, rec.StatusCode(), rec.Bytes(), time.Since(start), r.RemoteAddr, r.UserAgent())
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// withRecovery turns panics into internal server errors
+// so they get logged instead of just dropping the connection.
+func (h *handler) withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			h.log(r).Printf("panic: %v\n%s", v, debug.Stack())
+
+			if rec, ok := w.(*httputil.ResponseRecorder); ok && rec.Written() {
+				// Too late to respond anything else.
+				panic(http.ErrAbortHandler)
+			}
+
+			// Not through respondErr, which would log it again.
+			h.respondProblem(w, r, err2problem(fmt.Errorf("recovered from panic: %v", v)))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range id {
+		isValid := r >= 'a' && r <= 'z' ||
+			r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' ||
+			r == '-' || r == '_' || r == '.'
+		if !isValid {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
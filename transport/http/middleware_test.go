@@ -0,0 +1,79 @@
+package http
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+)
+
+func TestMiddleware(t *testing.T) {
+	logs := &bytes.Buffer{}
+	h := &handler{logger: log.New(logs, "", 0)}
+	var ctxRequestID string
+	srv := h.withRequestID(h.withAccessLog(h.withRecovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxRequestID, _ = r.Context().Value(passwordless.KeyRequestID).(string)
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))))
+
+	t.Run("request id", func(t *testing.T) {
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestIDHeader, "abc-123")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get(requestIDHeader); got != "abc-123" {
+			t.Errorf("%s = %q, want %q", requestIDHeader, got, "abc-123")
+		}
+
+		if ctxRequestID != "abc-123" {
+			t.Errorf("context request id = %q, want %q", ctxRequestID, "abc-123")
+		}
+
+		if !strings.Contains(logs.String(), "request_id=abc-123 method=GET") || !strings.Contains(logs.String(), "status=204") {
+			t.Errorf("access log = %q", logs.String())
+		}
+	})
+
+	t.Run("invalid request id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestIDHeader, "not valid\n")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get(requestIDHeader); len(got) != 32 {
+			t.Errorf("%s = %q, want a new one", requestIDHeader, got)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		logs.Reset()
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+		}
+
+		if !strings.Contains(rec.Body.String(), codeInternal) || strings.Contains(rec.Body.String(), "boom") {
+			t.Errorf("body = %s", rec.Body.String())
+		}
+
+		if !strings.Contains(logs.String(), "panic: boom") || !strings.Contains(logs.String(), "status=500") {
+			t.Errorf("logs = %q", logs.String())
+		}
+
+		if n := strings.Count(logs.String(), "boom"); n != 1 {
+			t.Errorf("panic logged %d times, want once", n)
+		}
+	})
+}
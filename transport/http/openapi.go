@@ -0,0 +1,29 @@
+package http
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"net/http"
+)
+
+// openAPIDoc describes the HTTP API.
+// Keep it in sync with the request and response types,
+// openapi_test.go checks they don't drift.
+//
+//go:embed openapi.json
+var openAPIDoc []byte
+
+func (h *handler) openAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, err := w.Write(openAPIDoc)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		h.log(r).Printf("could not write http response: %v\n", err)
+	}
+}
@@ -0,0 +1,201 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+	"github.com/nicolasparada/go-passwordless-demo/notification/bounce"
+)
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       string                    `json:"type"`
+	Format     string                    `json:"format"`
+	Nullable   bool                      `json:"nullable"`
+	Properties map[string]*openAPISchema `json:"properties"`
+	Items      *openAPISchema            `json:"items"`
+}
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// openAPITypes are the Go types behind each schema.
+// Request bodies are decoded without struct tags,
+// so their properties are matched case-insensitively.
+var openAPITypes = map[string]struct {
+	typ             reflect.Type
+	caseInsensitive bool
+}{
+	"SendMagicLinkRequest":      {reflect.TypeOf(sendMagicLinkReqBody{}), true},
+	"UpdateUserRequest":         {reflect.TypeOf(updateUserReqBody{}), true},
+	"ChangeUsernameRequest":     {reflect.TypeOf(changeUsernameReqBody{}), true},
+	"ConfirmPhoneNumberRequest": {reflect.TypeOf(confirmPhoneNumberReqBody{}), true},
+	"OutboxMessage":             {reflect.TypeOf(passwordless.OutboxMessage{}), false},
+	"Auth":                      {reflect.TypeOf(passwordless.Auth{}), false},
+	"User":                      {reflect.TypeOf(passwordless.User{}), false},
+	"UserProfile":               {reflect.TypeOf(passwordless.UserProfile{}), false},
+	"EmailEvent":                {reflect.TypeOf(bounce.Event{}), false},
+	"Problem":                   {reflect.TypeOf(problem{}), false},
+	"InvalidParam":              {reflect.TypeOf(invalidParam{}), false},
+}
+
+func TestOpenAPI(t *testing.T) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPIDoc, &doc); err != nil {
+		t.Fatalf("could not decode openapi.json: %v", err)
+	}
+
+	for name, tt := range openAPITypes {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[name]
+			if !ok {
+				t.Fatalf("missing %s schema", name)
+			}
+
+			checkSchemaProperties(t, doc, schema, tt.typ, tt.caseInsensitive)
+		})
+	}
+
+	t.Run("served", func(t *testing.T) {
+		h := NewHandler(nil, log.New(io.Discard, "", 0), Options{})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+
+		if !json.Valid(rec.Body.Bytes()) {
+			t.Error("served document is not valid JSON")
+		}
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		for _, path := range []string{"/api/send-magic-link", "/api/verify-magic-link", "/api/auth-user"} {
+			if _, ok := doc.Paths[path]; !ok {
+				t.Errorf("missing %s path", path)
+			}
+		}
+	})
+}
+
+func checkSchemaProperties(t *testing.T, doc openAPIDocument, schema *openAPISchema, typ reflect.Type, caseInsensitive bool) {
+	t.Helper()
+
+	fields := jsonFields(typ)
+	for name, f := range fields {
+		prop, ok := lookupProperty(schema.Properties, name, caseInsensitive)
+		if !ok {
+			t.Errorf("missing %q property", name)
+			continue
+		}
+
+		checkSchemaType(t, doc, name, prop, f.Type)
+	}
+
+	for name := range schema.Properties {
+		if _, ok := lookupField(fields, name, caseInsensitive); !ok {
+			t.Errorf("property %q has no field", name)
+		}
+	}
+}
+
+func checkSchemaType(t *testing.T, doc openAPIDocument, name string, prop *openAPISchema, typ reflect.Type) {
+	t.Helper()
+
+	if typ.Kind() == reflect.Ptr {
+		if !prop.Nullable {
+			t.Errorf("%q property should be nullable", name)
+		}
+		typ = typ.Elem()
+	} else if prop.Nullable {
+		t.Errorf("%q property should not be nullable", name)
+	}
+
+	if prop.Ref != "" {
+		refName := strings.TrimPrefix(prop.Ref, "#/components/schemas/")
+		want, ok := openAPITypes[refName]
+		if !ok || want.typ != typ {
+			t.Errorf("%q property refers to %s, want %s", name, refName, typ)
+		}
+		return
+	}
+
+	var wantType, wantFormat string
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		wantType, wantFormat = "string", "date-time"
+	case typ.Kind() == reflect.String:
+		wantType, wantFormat = "string", prop.Format
+	case typ.Kind() == reflect.Bool:
+		wantType = "boolean"
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		wantType = "integer"
+	case typ.Kind() == reflect.Slice:
+		wantType = "array"
+		if prop.Items != nil {
+			checkSchemaType(t, doc, name+"[]", prop.Items, typ.Elem())
+		}
+	default:
+		wantType = "object"
+	}
+
+	if prop.Type != wantType || prop.Format != wantFormat {
+		t.Errorf("%q property type = %s %s, want %s %s", name, prop.Type, prop.Format, wantType, wantFormat)
+	}
+}
+
+// jsonFields returns the JSON encoded fields of the given struct type
+// by their name.
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	out := map[string]reflect.StructField{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+
+		out[name] = f
+	}
+	return out
+}
+
+func lookupProperty(props map[string]*openAPISchema, name string, caseInsensitive bool) (*openAPISchema, bool) {
+	for k, v := range props {
+		if k == name || caseInsensitive && strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func lookupField(fields map[string]reflect.StructField, name string, caseInsensitive bool) (reflect.StructField, bool) {
+	for k, v := range fields {
+		if k == name || caseInsensitive && strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nicolasparada/go-passwordless-demo"
+)
+
+const (
+	problemContentType = "application/problem+json; charset=utf-8"
+	codeInternal       = passwordless.ErrorCodeInternal
+)
+
+// problem is an RFC 7807 problem details response body.
+// Clients should match on Code, which is stable,
+// instead of on Detail, which is meant for humans.
+type problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Code          string         `json:"code"`
+	InvalidParams []invalidParam `json:"invalidParams,omitempty"`
+}
+
+// invalidParam names the request parameter causing the problem.
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type problemKind struct {
+	status int
+	code   string
+}
+
+// transportProblems are the errors of this very transport.
+var transportProblems = map[error]problemKind{
+	errBadRequest:       {http.StatusBadRequest, "bad_request"},
+	errInvalidCSRFToken: {http.StatusForbidden, "invalid_csrf_token"},
+}
+
+var kindStatus = map[passwordless.ErrorKind]int{
+	passwordless.ErrorKindInternal:         http.StatusInternalServerError,
+	passwordless.ErrorKindInvalidArgument:  http.StatusUnprocessableEntity,
+	passwordless.ErrorKindPermissionDenied: http.StatusForbidden,
+	passwordless.ErrorKindNotFound:         http.StatusNotFound,
+	passwordless.ErrorKindUnauthenticated:  http.StatusUnauthorized,
+	passwordless.ErrorKindAlreadyExists:    http.StatusConflict,
+}
+
+// problemParams names the request parameter causing each error
+// as sent in JSON request bodies. See paramErr for other names.
+var problemParams = map[error]string{
+	passwordless.ErrInvalidEmail:             "email",
+	passwordless.ErrInvalidRedirectURI:       "redirectURI",
+	passwordless.ErrInvalidVerificationCode:  "code",
+	passwordless.ErrInvalidUsername:          "username",
+	passwordless.ErrInvalidDisplayName:       "displayName",
+	passwordless.ErrInvalidAvatarURL:         "avatarURL",
+	passwordless.ErrInvalidLocale:            "locale",
+	passwordless.ErrInvalidTimeZone:          "timeZone",
+	passwordless.ErrInvalidPhoneNumber:       "phoneNumber",
+	passwordless.ErrSMSUnavailable:           "phoneNumber",
+	passwordless.ErrInvalidPhoneNumberCode:   "code",
+	passwordless.ErrPhoneNumberCodeExpired:   "code",
+	passwordless.ErrInvalidOutboxMessageID:   "id",
+	passwordless.ErrInvalidSuppressionReason: "type",
+	passwordless.ErrEmailSuppressed:          "email",
+	passwordless.ErrUntrustedRedirectURI:     "redirectURI",
+	passwordless.ErrEmailTaken:               "email",
+	passwordless.ErrUsernameTaken:            "username",
+	passwordless.ErrPhoneNumberTaken:         "phoneNumber",
+}
+
+// paramErr names the request parameter causing err
+// when it differs from problemParams,
+// like in query strings and forms using snake case.
+type paramErr struct {
+	err   error
+	param string
+}
+
+func (e paramErr) Error() string { return e.err.Error() }
+func (e paramErr) Unwrap() error { return e.err }
+
+// problemKindOf returns the status and code of err.
+func problemKindOf(err error) problemKind {
+	if kind, ok := transportProblems[err]; ok {
+		return kind
+	}
+
+	return problemKind{
+		status: kindStatus[passwordless.ErrorKindOf(err)],
+		code:   passwordless.ErrorCode(err),
+	}
+}
+
+// err2problem describes err.
+// Unknown errors are internal ones and their details are not exposed.
+func err2problem(err error) problem {
+	param, hasParam := "", false
+	var pe paramErr
+	if errors.As(err, &pe) {
+		err, param = pe.err, pe.param
+		_, hasParam = problemParams[err]
+	} else {
+		param, hasParam = problemParams[err]
+	}
+
+	kind := problemKindOf(err)
+	if kind.code == codeInternal {
+		return problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusInternalServerError),
+			Status: http.StatusInternalServerError,
+			Detail: "internal server error",
+			Code:   codeInternal,
+		}
+	}
+
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(kind.status),
+		Status: kind.status,
+		Detail: err.Error(),
+		Code:   kind.code,
+	}
+	if hasParam {
+		p.InvalidParams = []invalidParam{{Name: param, Reason: err.Error()}}
+	}
+	return p
+}
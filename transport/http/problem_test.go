@@ -0,0 +1,137 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	passwordless "github.com/nicolasparada/go-passwordless-demo"
+)
+
+func TestHandler_respondErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want problem
+	}{
+		{
+			name: "invalid argument",
+			err:  passwordless.ErrInvalidEmail,
+			want: problem{
+				Type:          "about:blank",
+				Title:         "Unprocessable Entity",
+				Status:        http.StatusUnprocessableEntity,
+				Detail:        "invalid email",
+				Code:          "invalid_email",
+				InvalidParams: []invalidParam{{Name: "email", Reason: "invalid email"}},
+			},
+		},
+		{
+			name: "not found",
+			err:  passwordless.ErrUserNotFound,
+			want: problem{
+				Type:   "about:blank",
+				Title:  "Not Found",
+				Status: http.StatusNotFound,
+				Detail: "user not found",
+				Code:   "user_not_found",
+			},
+		},
+		{
+			name: "param name override",
+			err:  paramErr{passwordless.ErrInvalidRedirectURI, "redirect_uri"},
+			want: problem{
+				Type:          "about:blank",
+				Title:         "Unprocessable Entity",
+				Status:        http.StatusUnprocessableEntity,
+				Detail:        "invalid redirect URI",
+				Code:          "invalid_redirect_uri",
+				InvalidParams: []invalidParam{{Name: "redirect_uri", Reason: "invalid redirect URI"}},
+			},
+		},
+		{
+			name: "internal",
+			err:  errors.New("could not sql query: password authentication failed"),
+			want: problem{
+				Type:   "about:blank",
+				Title:  "Internal Server Error",
+				Status: http.StatusInternalServerError,
+				Detail: "internal server error",
+				Code:   codeInternal,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			h := &handler{logger: log.New(&logs, "", 0)}
+			rec := httptest.NewRecorder()
+			h.respondErr(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+			if rec.Code != tt.want.Status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want.Status)
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("content type = %q, want %q", ct, problemContentType)
+			}
+
+			if bytes.Contains(rec.Body.Bytes(), []byte("password")) {
+				t.Errorf("body = %s, want error details hidden", rec.Body)
+			}
+
+			var got problem
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %+v, want %+v", got, tt.want)
+			}
+
+			isInternal := tt.want.Status == http.StatusInternalServerError
+			if logged := logs.Len() != 0; logged != isInternal {
+				t.Errorf("logged = %v, want %v", logged, isInternal)
+			}
+		})
+	}
+}
+
+func TestHandler_redirectWithErr(t *testing.T) {
+	h := &handler{logger: log.New(io.Discard, "", 0)}
+	uri := &url.URL{Scheme: "https", Host: "example.org", Path: "/callback"}
+	rec := httptest.NewRecorder()
+	h.redirectWithErr(rec, httptest.NewRequest(http.MethodPost, "/api/verify-magic-link", nil), uri, passwordless.ErrVerificationCodeExpired)
+
+	if rec.Code != http.StatusFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	want := "https://example.org/callback#error=verification_code_expired&error_description=verification+code+expired"
+	if got := rec.Header().Get("Location"); got != want {
+		t.Errorf("location = %q, want %q", got, want)
+	}
+}
+
+func TestVerifyMagicLink_redirectURIParam(t *testing.T) {
+	h := NewHandler(&serviceStub{}, log.New(io.Discard, "", 0), Options{})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/verify-magic-link?redirect_uri=nope", nil))
+
+	var got problem
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []invalidParam{{Name: "redirect_uri", Reason: "invalid redirect URI"}}
+	if !reflect.DeepEqual(got.InvalidParams, want) {
+		t.Errorf("invalid params = %+v, want %+v", got.InvalidParams, want)
+	}
+}
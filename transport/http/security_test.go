@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("default", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		withSecurityHeaders(SecurityHeadersOptions{})(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		want := map[string]string{
+			"Content-Security-Policy":   DefaultContentSecurityPolicy,
+			"Referrer-Policy":           "no-referrer",
+			"X-Frame-Options":           "DENY",
+			"X-Content-Type-Options":    "nosniff",
+			"Strict-Transport-Security": "",
+		}
+		for k, v := range want {
+			if got := rec.Header().Get(k); got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		withSecurityHeaders(SecurityHeadersOptions{
+			ContentSecurityPolicy: "default-src 'none'",
+			HSTSMaxAge:            time.Hour,
+		})(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if got := rec.Header().Get("Content-Security-Policy"); got != "default-src 'none'" {
+			t.Errorf("Content-Security-Policy = %q", got)
+		}
+
+		if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains" {
+			t.Errorf("Strict-Transport-Security = %q", got)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo"
+)
+
+const (
+	sessionCookieName = "session"
+	// csrfCookieName is readable by scripts
+	// so they can send it back in the csrfHeader.
+	csrfCookieName = "csrf_token"
+	csrfHeader     = "X-CSRF-Token"
+	// csrfFormField is used by plain HTML forms instead of the csrfHeader.
+	csrfFormField = "csrf_token"
+)
+
+var errInvalidCSRFToken = errors.New("invalid csrf token")
+
+// setSession sets the session cookie with the auth token
+// along a new CSRF token.
+func (h *handler) setSession(w http.ResponseWriter, auth passwordless.Auth) error {
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    auth.Token,
+		Path:     "/",
+		Expires:  auth.ExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	setCSRFCookie(w, csrfToken, auth.ExpiresAt)
+	return nil
+}
+
+func clearSession(w http.ResponseWriter) {
+	for _, name := range []string{sessionCookieName, csrfCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == sessionCookieName,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// csrfToken returns the CSRF token cookie,
+// setting a new one when missing.
+func csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(csrfCookieName); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+
+	token, err := newCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	setCSRFCookie(w, token, time.Time{})
+	return token, nil
+}
+
+func setCSRFCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("could not generate csrf token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// checkCSRF follows the double-submit cookie pattern:
+// the request must send back the CSRF cookie value
+// either in the csrfHeader or in the csrfFormField.
+// Safe methods are not checked.
+func checkCSRF(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+
+	c, err := r.Cookie(csrfCookieName)
+	if err != nil || c.Value == "" {
+		return errInvalidCSRFToken
+	}
+
+	got := r.Header.Get(csrfHeader)
+	if got == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		got = r.PostFormValue(csrfFormField)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(got), []byte(c.Value)) != 1 {
+		return errInvalidCSRFToken
+	}
+
+	return nil
+}
+
+// logout clears the session cookies.
+// Auth tokens are stateless and not revoked,
+// so a copy of the session token keeps working until it expires.
+func (h *handler) logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clearSession(w)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nicolasparada/go-passwordless-demo/transport"
+)
+
+func newSessionHandler(svc transport.Service) http.Handler {
+	return NewHandler(svc, log.New(io.Discard, "", 0), Options{CookieSessions: true})
+}
+
+func withSessionCookies(r *http.Request) *http.Request {
+	r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: testAuthToken})
+	r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: testCSRFToken})
+	return r
+}
+
+func TestCSRF(t *testing.T) {
+	newReq := func() *http.Request {
+		return httptest.NewRequest(http.MethodPatch, "/api/me", strings.NewReader(`{}`))
+	}
+
+	missing := withSessionCookies(newReq())
+
+	mismatched := withSessionCookies(newReq())
+	mismatched.Header.Set(csrfHeader, "other-token")
+
+	matching := withSessionCookies(newReq())
+	matching.Header.Set(csrfHeader, testCSRFToken)
+
+	bearer := newReq()
+	bearer.Header.Set("Authorization", "Bearer "+testAuthToken)
+	bearer.AddCookie(&http.Cookie{Name: csrfCookieName, Value: testCSRFToken})
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{name: "missing token", req: missing, wantStatus: http.StatusForbidden},
+		{name: "mismatched token", req: mismatched, wantStatus: http.StatusForbidden},
+		{name: "matching token", req: matching, wantStatus: http.StatusOK},
+		{name: "bearer token", req: bearer, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newSessionHandler(&serviceStub{}).ServeHTTP(rec, tt.req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestVerifyMagicLink_loginCSRF(t *testing.T) {
+	newReq := func(form url.Values) *http.Request {
+		form.Set("email", "jane@example.org")
+		form.Set("code", "00000000-0000-4000-8000-000000000000")
+		form.Set("redirect_uri", "https://example.org/callback")
+		r := httptest.NewRequest(http.MethodPost, "/api/verify-magic-link", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: testCSRFToken})
+		return r
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		svc := &serviceStub{}
+		rec := httptest.NewRecorder()
+		newSessionHandler(svc).ServeHTTP(rec, newReq(url.Values{}))
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+
+		if svc.verified {
+			t.Error("VerifyMagicLink() called without csrf token")
+		}
+	})
+
+	t.Run("matching token", func(t *testing.T) {
+		svc := &serviceStub{}
+		rec := httptest.NewRecorder()
+		newSessionHandler(svc).ServeHTTP(rec, newReq(url.Values{csrfFormField: []string{testCSRFToken}}))
+
+		if rec.Code != http.StatusFound {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusFound)
+		}
+
+		if !svc.verified {
+			t.Error("VerifyMagicLink() not called")
+		}
+
+		c := responseCookie(rec, sessionCookieName)
+		if c == nil || c.Value != testAuthToken || !c.HttpOnly {
+			t.Errorf("session cookie = %+v, want HttpOnly with the auth token", c)
+		}
+
+		if loc := rec.Header().Get("Location"); strings.Contains(loc, testAuthToken) {
+			t.Errorf("location = %q, want no auth token", loc)
+		}
+	})
+}
+
+func TestLogout(t *testing.T) {
+	req := withSessionCookies(httptest.NewRequest(http.MethodPost, "/api/logout", nil))
+	req.Header.Set(csrfHeader, testCSRFToken)
+
+	rec := httptest.NewRecorder()
+	newSessionHandler(&serviceStub{}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	for _, name := range []string{sessionCookieName, csrfCookieName} {
+		c := responseCookie(rec, name)
+		if c == nil || c.MaxAge >= 0 || c.Value != "" {
+			t.Errorf("%s cookie = %+v, want expired", name, c)
+		}
+	}
+}
+
+func responseCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
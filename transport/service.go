@@ -8,9 +8,16 @@ import (
 )
 
 type Service interface {
-	SendMagicLink(ctx context.Context, email, redirectURI string) error
+	SendMagicLink(ctx context.Context, to, redirectURI string) (passwordless.OutboxMessage, error)
+	OutboxMessage(ctx context.Context, id string) (passwordless.OutboxMessage, error)
 	ValidateRedirectURI(rawurl string) (*url.URL, error)
+	IsTrustedOrigin(origin string) bool
 	VerifyMagicLink(ctx context.Context, email, code string, username *string) (passwordless.Auth, error)
-	ParseAuthToken(token string) (userID string, err error)
+	ParseAuthToken(ctx context.Context, token string) (userID string, err error)
 	AuthUser(ctx context.Context) (passwordless.User, error)
+	UpdateUser(ctx context.Context, params passwordless.UpdateUserParams) (passwordless.User, error)
+	ChangeUsername(ctx context.Context, username string) (passwordless.User, error)
+	ConfirmPhoneNumber(ctx context.Context, code string) (passwordless.User, error)
+	UserByUsername(ctx context.Context, username string) (passwordless.User, error)
+	SuppressEmail(ctx context.Context, email string, reason passwordless.SuppressionReason, detail string) (passwordless.Suppression, error)
 }
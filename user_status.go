@@ -0,0 +1,69 @@
+package passwordless
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+type UserStatus string
+
+const (
+	UserStatusActive    UserStatus = "active"
+	UserStatusSuspended UserStatus = "suspended"
+	UserStatusBanned    UserStatus = "banned"
+)
+
+func (s UserStatus) valid() bool {
+	switch s {
+	case UserStatusActive, UserStatusSuspended, UserStatusBanned:
+		return true
+	}
+
+	return false
+}
+
+// Active tells whether the user is allowed to login.
+// Suspended and banned users become active again once their status expires.
+func (u User) Active() bool {
+	if u.Status == UserStatusActive {
+		return true
+	}
+
+	return u.StatusExpiresAt != nil && u.StatusExpiresAt.Before(time.Now())
+}
+
+// statusErr returns the error matching the user status if not active.
+func (u User) statusErr() error {
+	if u.Active() {
+		return nil
+	}
+
+	if u.Status == UserStatusBanned {
+		return ErrUserBanned
+	}
+
+	return ErrUserSuspended
+}
+
+// SetUserStatus suspends, bans or reactivates the given user.
+// A nil expiresAt means the status doesn't expire.
+// It's meant for administration purposes, so it doesn't require authentication.
+func (svc *Service) SetUserStatus(ctx context.Context, userID string, status UserStatus, reason string, expiresAt *time.Time) (User, error) {
+	if !status.valid() {
+		return User{}, ErrInvalidUserStatus
+	}
+
+	reason = strings.TrimSpace(reason)
+	if status == UserStatusActive {
+		reason = ""
+		expiresAt = nil
+	}
+
+	if expiresAt != nil {
+		t := expiresAt.UTC()
+		expiresAt = &t
+	}
+
+	return svc.Repository.UpdateUserStatus(ctx, userID, status, reason, expiresAt)
+}
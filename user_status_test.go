@@ -0,0 +1,66 @@
+package passwordless
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUser_statusErr(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		status    UserStatus
+		expiresAt *time.Time
+		wantErr   error
+	}{
+		{name: "active", status: UserStatusActive},
+		{name: "suspended", status: UserStatusSuspended, wantErr: ErrUserSuspended},
+		{name: "suspended until later", status: UserStatusSuspended, expiresAt: &future, wantErr: ErrUserSuspended},
+		{name: "suspension expired", status: UserStatusSuspended, expiresAt: &past},
+		{name: "banned", status: UserStatusBanned, wantErr: ErrUserBanned},
+		{name: "ban expired", status: UserStatusBanned, expiresAt: &past},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepo()
+			svc := newTestService(t, repo)
+			ctx := context.Background()
+
+			u := storeTestUser(t, repo, "john")
+			if _, err := repo.UpdateUserStatus(ctx, u.ID, tt.status, "", tt.expiresAt); err != nil {
+				t.Fatalf("UpdateUserStatus() error = %v", err)
+			}
+
+			t.Run("ParseAuthToken", func(t *testing.T) {
+				token, err := svc.authTokenCodec().EncodeToString(u.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := svc.ParseAuthToken(ctx, token); err != tt.wantErr {
+					t.Errorf("ParseAuthToken() error = %v, want %v", err, tt.wantErr)
+				}
+			})
+
+			t.Run("SendMagicLink", func(t *testing.T) {
+				if _, err := svc.SendMagicLink(ctx, u.Email, "https://example.org/callback"); err != tt.wantErr {
+					t.Errorf("SendMagicLink() error = %v, want %v", err, tt.wantErr)
+				}
+			})
+
+			t.Run("VerifyMagicLink", func(t *testing.T) {
+				vc, err := repo.StoreVerificationCode(ctx, u.Email)
+				if err != nil {
+					t.Fatalf("StoreVerificationCode() error = %v", err)
+				}
+
+				if _, err := svc.VerifyMagicLink(ctx, u.Email, vc.Code, nil); err != tt.wantErr {
+					t.Errorf("VerifyMagicLink() error = %v, want %v", err, tt.wantErr)
+				}
+			})
+		})
+	}
+}
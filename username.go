@@ -0,0 +1,107 @@
+package passwordless
+
+import (
+	"context"
+	"time"
+
+	"github.com/nicolasparada/go-passwordless-demo/notification"
+)
+
+// ReleasedUsername is a username a user had before changing it.
+// It stays reserved to that user for a while so nobody else can impersonate them.
+type ReleasedUsername struct {
+	Username   string
+	UserID     string
+	ReleasedAt time.Time
+}
+
+func (rel ReleasedUsername) Reserved() bool {
+	return rel.ReleasedAt.Add(usernameReservationTTL).After(time.Now())
+}
+
+// ChangeUsername changes the authenticated user username.
+// The previous username gets reserved to them.
+func (svc *Service) ChangeUsername(ctx context.Context, username string) (User, error) {
+	var u User
+
+	authUserID, ok := ctx.Value(KeyAuthUserID).(string)
+	if !ok {
+		return u, ErrUnauthenticated
+	}
+
+	if !isValidUsername(username) {
+		return u, ErrInvalidUsername
+	}
+
+	err := svc.Repository.ExecuteTx(ctx, func(ctx context.Context) error {
+		var err error
+		u, err = svc.Repository.User(ctx, authUserID)
+		if err != nil {
+			return err
+		}
+
+		if u.Username == username {
+			return nil
+		}
+
+		rel, err := svc.Repository.ReleasedUsername(ctx, username)
+		if err != nil && err != ErrReleasedUsernameNotFound {
+			return err
+		}
+
+		if err == nil && rel.Reserved() && rel.UserID != authUserID {
+			return ErrUsernameTaken
+		}
+
+		oldUsername := u.Username
+		u, err = svc.Repository.UpdateUsername(ctx, authUserID, username)
+		if err != nil {
+			return err
+		}
+
+		_, err = svc.Repository.StoreReleasedUsername(ctx, authUserID, oldUsername)
+		if err != nil {
+			return err
+		}
+
+		return svc.notifyUser(ctx, u, svc.accountChangeData(ctx, u, notification.AccountChangeUsername))
+	})
+	if err != nil {
+		return u, err
+	}
+
+	svc.wakeOutboxDispatcher()
+
+	return u, nil
+}
+
+// UserByUsername finds a user by its current username,
+// falling back to the owner of a released one.
+// Compare the returned user username to detect the latter.
+func (svc *Service) UserByUsername(ctx context.Context, username string) (User, error) {
+	var u User
+
+	if _, ok := ctx.Value(KeyAuthUserID).(string); !ok {
+		return u, ErrUnauthenticated
+	}
+
+	if !isValidUsername(username) {
+		return u, ErrInvalidUsername
+	}
+
+	u, err := svc.Repository.UserByUsername(ctx, username)
+	if err != ErrUserNotFound {
+		return u, err
+	}
+
+	rel, err := svc.Repository.ReleasedUsername(ctx, username)
+	if err == ErrReleasedUsernameNotFound {
+		return u, ErrUserNotFound
+	}
+
+	if err != nil {
+		return u, err
+	}
+
+	return svc.Repository.User(ctx, rel.UserID)
+}
@@ -0,0 +1,83 @@
+package passwordless
+
+import (
+	"testing"
+	"time"
+)
+
+func TestService_ChangeUsername(t *testing.T) {
+	tests := []struct {
+		name string
+		// byOwner changes back to the released username as its owner.
+		byOwner bool
+		expired bool
+		wantErr error
+	}{
+		{name: "reserved", wantErr: ErrUsernameTaken},
+		{name: "reclaimed by owner", byOwner: true},
+		{name: "reservation expired", expired: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepo()
+			svc := newTestService(t, repo)
+
+			john := storeTestUser(t, repo, "john")
+			jane := storeTestUser(t, repo, "jane")
+
+			if _, err := svc.ChangeUsername(authContext(john), "johnny"); err != nil {
+				t.Fatalf("ChangeUsername() error = %v", err)
+			}
+
+			if tt.expired {
+				repo.released[0].ReleasedAt = time.Now().Add(-usernameReservationTTL - time.Second)
+			}
+
+			u := jane
+			if tt.byOwner {
+				u = john
+			}
+
+			got, err := svc.ChangeUsername(authContext(u), "john")
+			if err != tt.wantErr {
+				t.Fatalf("ChangeUsername() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && (got.ID != u.ID || got.Username != "john") {
+				t.Errorf("ChangeUsername() = %+v, want %s with username john", got, u.Username)
+			}
+		})
+	}
+}
+
+func TestService_UserByUsername(t *testing.T) {
+	repo := newMemRepo()
+	svc := newTestService(t, repo)
+
+	john := storeTestUser(t, repo, "john")
+	if _, err := svc.ChangeUsername(authContext(john), "johnny"); err != nil {
+		t.Fatalf("ChangeUsername() error = %v", err)
+	}
+
+	tests := []struct {
+		username     string
+		wantUsername string
+		wantErr      error
+	}{
+		{username: "johnny", wantUsername: "johnny"},
+		{username: "john", wantUsername: "johnny"},
+		{username: "jane", wantErr: ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			got, err := svc.UserByUsername(authContext(john), tt.username)
+			if err != tt.wantErr {
+				t.Fatalf("UserByUsername(%q) error = %v, want %v", tt.username, err, tt.wantErr)
+			}
+
+			if err == nil && (got.ID != john.ID || got.Username != tt.wantUsername) {
+				t.Errorf("UserByUsername(%q) = %+v, want john as %s", tt.username, got, tt.wantUsername)
+			}
+		})
+	}
+}
@@ -1,15 +1,15 @@
 export function loginCallback() {
     const data = new URLSearchParams(location.hash.substring(1))
     if (data.has("error")) {
-        const errMsg = decodeURIComponent(data.get("error"))
-        alert(errMsg)
+        const errCode = decodeURIComponent(data.get("error"))
+        alert(data.has("error_description") ? decodeURIComponent(data.get("error_description")) : errCode)
 
         if (!data.has("retry_uri")) {
             location.assign("/")
             return
         }
 
-        if (errMsg === "user not found") {
+        if (errCode === "user_not_found") {
             const ok = confirm("do you want to create a new account?")
             if (!ok) {
                 location.assign("/")
@@ -30,7 +30,8 @@ export function loginCallback() {
         return
     }
 
-    if (["token", "expires_at", "user.id", "user.email", "user.username"].every(k => data.has(k))) {
+    // The token is missing when the server keeps it in a session cookie.
+    if (["expires_at", "user.id", "user.email", "user.username"].every(k => data.has(k))) {
         setLocalAuth(data)
         location.replace("/")
         return
@@ -49,7 +50,7 @@ export function setLocalAuth(data) {
             email: decodeURIComponent(data.get("user.email")),
             username: decodeURIComponent(data.get("user.username")),
         },
-        token: decodeURIComponent(data.get("token")),
+        token: data.has("token") ? decodeURIComponent(data.get("token")) : null,
         expiresAt: decodeURIComponent(data.get("expires_at")),
     }))
 }
@@ -62,7 +63,7 @@ export function setLocalAuth(data) {
  *
  * @typedef {object} Auth
  * @prop {User} user
- * @prop {string} token
+ * @prop {string|null} token null when using the session cookie.
  * @prop {Date} expiresAt
  *
  * @returns {Auth|null}
@@ -77,7 +78,7 @@ export function getLocalAuth() {
         const auth = JSON.parse(authItem)
         if (typeof auth !== "object"
             || auth === null
-            || typeof auth.token !== "string"
+            || (typeof auth.token !== "string" && auth.token !== null)
             || typeof auth.expiresAt !== "string") {
             return null
         }
@@ -101,3 +102,34 @@ export function getLocalAuth() {
 
     return null
 }
+
+/**
+ * Headers to authenticate a request.
+ * Session cookie requests send the CSRF token instead of the auth token.
+ *
+ * @param {Auth} auth
+ * @returns {Object<string, string>}
+ */
+export function authHeaders(auth) {
+    if (auth.token !== null) {
+        return { "authorization": "Bearer " + auth.token }
+    }
+
+    const csrfToken = getCookie("csrf_token")
+    return csrfToken === null ? {} : { "x-csrf-token": csrfToken }
+}
+
+/**
+ * @param {string} name
+ * @returns {string|null}
+ */
+function getCookie(name) {
+    for (const pair of document.cookie.split(";")) {
+        const [k, ...v] = pair.trim().split("=")
+        if (k === name) {
+            return decodeURIComponent(v.join("="))
+        }
+    }
+
+    return null
+}
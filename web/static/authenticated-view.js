@@ -1,3 +1,4 @@
+import { authHeaders } from "./auth.js"
 import { parseResponse } from "./http.js"
 
 const tmpl = document.createElement("template")
@@ -16,10 +17,10 @@ tmpl.innerHTML = `
 export function authenticatedView(auth) {
     const view = /** @type {DocumentFragment} */ (tmpl.content.cloneNode(true))
     view.querySelector("[data-ref=username]").textContent = auth.user.username
-    view.querySelector("#logout-btn").addEventListener("click", onLogoutBtnClick)
+    view.querySelector("#logout-btn").addEventListener("click", ev => onLogoutBtnClick(ev, auth))
 
     setTimeout(() => {
-        fetchAuthUser(auth.token).then(authUser => {
+        fetchAuthUser(auth).then(authUser => {
             console.log(authUser)
         }).catch(err => {
             console.error(err)
@@ -31,23 +32,40 @@ export function authenticatedView(auth) {
 
 /**
  * @param {Event} ev
+ * @param {import("./auth.js").Auth} auth
  */
-function onLogoutBtnClick(ev) {
+function onLogoutBtnClick(ev, auth) {
     const btn = /** @type {HTMLButtonElement} */ (ev.currentTarget)
     btn.disabled = true
-    localStorage.removeItem("auth")
-    location.replace("/")
+
+    // Only the server can clear the HttpOnly session cookie.
+    const loggedOut = auth.token === null ? logout(auth) : Promise.resolve()
+    loggedOut.catch(err => {
+        console.error(err)
+    }).finally(() => {
+        localStorage.removeItem("auth")
+        location.replace("/")
+    })
 }
 
 /**
- * @param {string} token
+ * @param {import("./auth.js").Auth} auth
  * @returns {Promise<import("./auth.js").User>}
  */
-function fetchAuthUser(token) {
+function fetchAuthUser(auth) {
     return fetch("/api/auth-user", {
         method: "GET",
-        headers: {
-            "authorization": "Bearer " + token,
-        },
+        headers: authHeaders(auth),
+    }).then(parseResponse)
+}
+
+/**
+ * @param {import("./auth.js").Auth} auth
+ * @returns {Promise<void>}
+ */
+function logout(auth) {
+    return fetch("/api/logout", {
+        method: "POST",
+        headers: authHeaders(auth),
     }).then(parseResponse)
 }
@@ -6,8 +6,8 @@ tmpl.innerHTML = `
         <h1>Login</h1>
         <form name="login-form">
             <div class="btn-grp">
-                <label for="email-input">Email:</label>
-                <input id="email-input" name="email" autocomplete="email" placeholder="Email" required>
+                <label for="email-input">Email or phone:</label>
+                <input id="email-input" name="email" autocomplete="email" placeholder="Email or +56 9 1234 5678" required>
             </div>
             <button>Login</button>
         </form>
@@ -29,13 +29,16 @@ function onLoginFormSubmit(ev) {
     const input = form.querySelector("input")
     const button = form.querySelector("button")
 
-    const email = input.value
+    const to = input.value.trim()
+    const isPhoneNumber = to.startsWith("+")
 
     input.disabled = true
     button.disabled = true
 
-    sendMagicLink(email).then(() => {
-        alert("Magic link sent. Go check your inbox to login")
+    sendMagicLink(isPhoneNumber ? { phoneNumber: to } : { email: to }).then(() => {
+        alert(isPhoneNumber
+            ? "Magic link sent. Go check your SMS messages to login"
+            : "Magic link sent. Go check your inbox to login")
     }).catch(err => {
         console.error(err)
         alert(err.message)
@@ -46,16 +49,16 @@ function onLoginFormSubmit(ev) {
 }
 
 /**
- * @param {string} email
+ * @param {{email?: string, phoneNumber?: string}} to
  * @param {string=} redirectURI
  * @returns {Promise<void>}
  */
-function sendMagicLink(email, redirectURI = location.origin + "/login-callback") {
+function sendMagicLink(to, redirectURI = location.origin + "/login-callback") {
     return fetch("/api/send-magic-link", {
         method: "POST",
         headers: {
             "content-type": "application/json; charset=utf-8",
         },
-        body: JSON.stringify({ email, redirectURI }),
+        body: JSON.stringify({ ...to, redirectURI }),
     }).then(parseResponse)
 }
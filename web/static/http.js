@@ -4,11 +4,32 @@
 export function parseResponse(resp) {
     return resp.clone().json().catch(() => resp.text()).then(body => {
         if (!resp.ok) {
-            const msg = typeof body === "string" && body !== "" ? body : resp.statusText
-            const err = new Error(msg)
-            return Promise.reject(err)
+            return Promise.reject(responseError(resp, body))
         }
 
         return body
     })
 }
+
+/**
+ * Error from an application/problem+json response.
+ * Match on code since messages are meant for humans.
+ *
+ * @typedef {Error & {code: string, status: number}} ProblemError
+ */
+
+/**
+ * @param {Response} resp
+ * @param {any} body
+ * @returns {ProblemError}
+ */
+function responseError(resp, body) {
+    const isProblem = typeof body === "object" && body !== null && typeof body.code === "string"
+    const msg = isProblem
+        ? body.detail || body.title
+        : typeof body === "string" && body !== "" ? body : resp.statusText
+    const err = /** @type {ProblemError} */ (new Error(msg))
+    err.code = isProblem ? body.code : ""
+    err.status = resp.status
+    return err
+}